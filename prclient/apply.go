@@ -0,0 +1,507 @@
+package prclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Manifest is a PavedRoad object decoded from a manifest document. Exactly
+// one of Token and UserIdMapper is set, according to Kind.
+type Manifest struct {
+	Kind         string
+	Token        *Token
+	UserIdMapper *UserIdMapper
+
+	// Source locates the document, e.g. tokens.yaml#2.
+	Source string
+}
+
+// Kinds of the objects handled by Apply, matched case-insensitively and
+// without the pr prefix.
+const (
+	TokenKind        = "PrToken"
+	UserIdMapperKind = "prUserIdMapper"
+)
+
+// DecodeManifests reads the YAML or JSON documents of r, separated by ---
+// lines, and decodes them according to their kind. A document may also be
+// a JSON array or an object of kind List holding the objects in items.
+// name is used in the Source of the manifests and in errors.
+func DecodeManifests(r io.Reader, name string) ([]Manifest, error) {
+	docs, err := splitDocuments(r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	var manifests []Manifest
+	for i, doc := range docs {
+		source := fmt.Sprintf("%s#%d", name, i+1)
+		j, err := yaml.YAMLToJSON(doc)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		items, err := manifestItems(j)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", source, err)
+		}
+		for k, item := range items {
+			m, err := decodeManifest(item)
+			if len(items) > 1 {
+				m.Source = fmt.Sprintf("%s[%d]", source, k)
+			} else {
+				m.Source = source
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", m.Source, err)
+			}
+			manifests = append(manifests, m)
+		}
+	}
+	return manifests, nil
+}
+
+// splitDocuments splits a YAML stream into its non-empty documents.
+func splitDocuments(r io.Reader) ([][]byte, error) {
+	var docs [][]byte
+	var doc bytes.Buffer
+	flush := func() {
+		if len(bytes.TrimSpace(doc.Bytes())) > 0 {
+			docs = append(docs, append([]byte(nil), doc.Bytes()...))
+		}
+		doc.Reset()
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		if strings.TrimRight(sc.Text(), " \t") == "---" {
+			flush()
+			continue
+		}
+		doc.Write(sc.Bytes())
+		doc.WriteByte('\n')
+	}
+	flush()
+	return docs, sc.Err()
+}
+
+// manifestItems returns the objects of a JSON document, expanding arrays
+// and lists.
+func manifestItems(doc []byte) ([]json.RawMessage, error) {
+	doc = bytes.TrimSpace(doc)
+	if bytes.Equal(doc, []byte("null")) {
+		return nil, nil
+	}
+	if len(doc) > 0 && doc[0] == '[' {
+		var items []json.RawMessage
+		err := json.Unmarshal(doc, &items)
+		return items, err
+	}
+
+	var list struct {
+		Kind  string            `json:"kind"`
+		Items []json.RawMessage `json:"items"`
+	}
+	if err := json.Unmarshal(doc, &list); err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(list.Kind, "List") {
+		return list.Items, nil
+	}
+	return []json.RawMessage{doc}, nil
+}
+
+func decodeManifest(doc json.RawMessage) (Manifest, error) {
+	var head struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(doc, &head); err != nil {
+		return Manifest{}, err
+	}
+
+	m := Manifest{Kind: head.Kind}
+	var v interface{}
+	switch normalizeKind(head.Kind) {
+	case normalizeKind(TokenKind):
+		m.Token = new(Token)
+		v = m.Token
+	case normalizeKind(UserIdMapperKind):
+		m.UserIdMapper = new(UserIdMapper)
+		v = m.UserIdMapper
+	case "":
+		return m, fmt.Errorf("kind is required")
+	default:
+		return m, fmt.Errorf("unknown kind %q", head.Kind)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return m, err
+	}
+	return m, nil
+}
+
+func normalizeKind(kind string) string {
+	return strings.TrimPrefix(strings.ToLower(kind), "pr")
+}
+
+// ApplyOptions specifies optional parameters to Client.Apply.
+type ApplyOptions struct {
+	// DryRun computes the changes without making them.
+	DryRun bool
+
+	// Prune deletes the objects missing from the manifests. Only the kinds
+	// and namespaces the manifests hold objects of are pruned, and tokens
+	// of a rotation chain (see TokensService.Rotate) are kept.
+	Prune bool
+}
+
+// ApplyAction is what Client.Apply does to an object.
+type ApplyAction string
+
+// Actions taken by Client.Apply.
+const (
+	ApplyCreate    ApplyAction = "create"
+	ApplyReplace   ApplyAction = "replace"
+	ApplyDelete    ApplyAction = "delete"
+	ApplyUnchanged ApplyAction = "unchanged"
+)
+
+// ApplyChange is a step of the plan computed by Client.Apply.
+type ApplyChange struct {
+	Action    ApplyAction
+	Kind      string
+	Namespace string
+	Key       string   // UID of tokens, credential of mappers (secret, hashed by String); empty for tokens to create
+	Name      string   // name of tokens
+	Fields    []string // paths of the fields replaced, e.g. metadata.site
+	Source    string   // manifest of the object, empty for deletions
+}
+
+func (c ApplyChange) String() string {
+	key := displayKey(c.Kind, c.Key)
+	id := key
+	if c.Name != "" {
+		id = c.Name
+		if key != "" {
+			id += " (" + key + ")"
+		}
+	}
+	s := fmt.Sprintf("%s %s/%s: %s", c.Kind, c.Namespace, id, c.Action)
+	if len(c.Fields) > 0 {
+		s += " " + strings.Join(c.Fields, ", ")
+	}
+	return s
+}
+
+// displayKey returns the key of an object of kind as printed in plans and
+// errors. The credentials of user ID mappers are secret, so a short hash
+// telling them apart is printed instead.
+func displayKey(kind, key string) string {
+	if kind != UserIdMapperKind || key == "" {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:6])
+}
+
+// Apply makes the tokens and user ID mappers of the server match manifests,
+// as decoded by DecodeManifests: objects are created when missing and
+// replaced when they differ. Fields set by the server, such as creation
+// times, are ignored, and so is an empty token secret, so manifests can be
+// kept in git without secrets. With opt.Prune, the objects missing from the
+// manifests are deleted.
+//
+// Tokens are matched by UID or, if the manifest has none, by name. When
+// several tokens have that name, as after a rotation, the active one is
+// matched; Apply fails if there is not exactly one. Tokens are applied in
+// their Metadata.Namespace, if set, and mappers in the namespace of c.
+//
+// Apply returns the plan, i.e. the changes made, or to be made if
+// opt.DryRun is set. On error, the changes made so far are returned.
+func (c *Client) Apply(ctx context.Context, manifests []Manifest, opt *ApplyOptions) ([]ApplyChange, error) {
+	if opt == nil {
+		opt = &ApplyOptions{}
+	}
+
+	tokens := map[string][]desired[Token]{}
+	var mappers []desired[UserIdMapper]
+	for _, m := range manifests {
+		switch {
+		case m.Token != nil:
+			ns := m.Token.Metadata.Namespace
+			if ns == namespaceOf(c.BaseURL) {
+				ns = ""
+			}
+			tokens[ns] = append(tokens[ns], desired[Token]{m.Token, m.Source})
+		case m.UserIdMapper != nil:
+			mappers = append(mappers, desired[UserIdMapper]{m.UserIdMapper, m.Source})
+		}
+	}
+
+	var plan []ApplyChange
+	for _, ns := range sortedKeys(tokens) {
+		changes, err := applyKind(ctx, c.inNamespace(ns), tokenApplier, tokens[ns], opt)
+		plan = append(plan, changes...)
+		if err != nil {
+			return plan, err
+		}
+	}
+	if len(mappers) > 0 {
+		changes, err := applyKind(ctx, c, mapperApplier, mappers, opt)
+		plan = append(plan, changes...)
+		if err != nil {
+			return plan, err
+		}
+	}
+	return plan, nil
+}
+
+// desired is an object of a manifest.
+type desired[T any] struct {
+	obj    *T
+	source string
+}
+
+// inNamespace returns c, or a view of c for namespace ns if set.
+func (c *Client) inNamespace(ns string) *Client {
+	if ns == "" {
+		return c
+	}
+	return c.Namespace(ns)
+}
+
+// applier gives Apply access to a kind of object.
+type applier[T any] struct {
+	kind    string
+	list    func(ctx context.Context, c *Client) ([]*T, error)
+	create  func(ctx context.Context, c *Client, obj *T) error
+	replace func(ctx context.Context, c *Client, key string, obj *T) error
+	delete  func(ctx context.Context, c *Client, key string) error
+	key     func(*T) string
+	name    func(*T) string
+
+	// match returns the live object desired refers to, if any.
+	match func(desired *T, live []*T) (*T, error)
+
+	// keep reports whether l must not be pruned. It may be nil.
+	keep func(l *T, live []*T) bool
+
+	// merge copies the fields set by the server from live to desired. The
+	// kind only selects the type of manifests, so it is copied too.
+	merge func(desired, live *T)
+}
+
+var tokenApplier = &applier[Token]{
+	kind: TokenKind,
+	list: func(ctx context.Context, c *Client) ([]*Token, error) {
+		return c.Token.ListAll(ctx, nil)
+	},
+	create: func(ctx context.Context, c *Client, t *Token) error {
+		_, _, err := c.Token.Create(ctx, *t)
+		return err
+	},
+	replace: func(ctx context.Context, c *Client, uid string, t *Token) error {
+		_, _, err := c.Token.Replace(ctx, t, uid)
+		return err
+	},
+	delete: func(ctx context.Context, c *Client, uid string) error {
+		_, err := c.Token.Delete(ctx, uid)
+		return err
+	},
+	key:  func(t *Token) string { return t.Metadata.UID },
+	name: func(t *Token) string { return t.Metadata.Name },
+	match: func(desired *Token, live []*Token) (*Token, error) {
+		var named, active []*Token
+		for _, t := range live {
+			if desired.Metadata.UID != "" && t.Metadata.UID == desired.Metadata.UID {
+				return t, nil
+			}
+			if desired.Metadata.UID == "" && desired.Metadata.Name != "" && t.Metadata.Name == desired.Metadata.Name {
+				named = append(named, t)
+				if t.Active {
+					active = append(active, t)
+				}
+			}
+		}
+		switch {
+		case len(named) == 0:
+			return nil, nil
+		case len(named) == 1:
+			return named[0], nil
+		case len(active) == 1:
+			return active[0], nil
+		}
+		return nil, fmt.Errorf("%d tokens are named %q and %d of them are active, set metadata.uid to choose one",
+			len(named), desired.Metadata.Name, len(active))
+	},
+	keep: func(l *Token, live []*Token) bool {
+		if l.Metadata.RotatedFrom != "" {
+			return true
+		}
+		for _, t := range live {
+			if t.Metadata.RotatedFrom == l.Metadata.UID {
+				return true
+			}
+		}
+		return false
+	},
+	merge: func(desired, live *Token) {
+		desired.Kind = live.Kind
+		if desired.APIVersion == "" {
+			desired.APIVersion = live.APIVersion
+		}
+		desired.Metadata.UID = live.Metadata.UID
+		if desired.Metadata.Namespace == "" {
+			desired.Metadata.Namespace = live.Metadata.Namespace
+		}
+		if desired.Metadata.Token == "" {
+			desired.Metadata.Token = live.Metadata.Token
+		}
+		if desired.Metadata.RotatedFrom == "" {
+			desired.Metadata.RotatedFrom = live.Metadata.RotatedFrom
+		}
+		desired.Created, desired.Updated, desired.LastUsed = live.Created, live.Updated, live.LastUsed
+	},
+}
+
+var mapperApplier = &applier[UserIdMapper]{
+	kind: UserIdMapperKind,
+	list: func(ctx context.Context, c *Client) ([]*UserIdMapper, error) {
+		return c.UserIdMapper.ListAll(ctx, nil)
+	},
+	create: func(ctx context.Context, c *Client, m *UserIdMapper) error {
+		_, _, err := c.UserIdMapper.Create(ctx, *m)
+		return err
+	},
+	replace: func(ctx context.Context, c *Client, cred string, m *UserIdMapper) error {
+		_, _, err := c.UserIdMapper.Replace(ctx, m, cred)
+		return err
+	},
+	delete: func(ctx context.Context, c *Client, cred string) error {
+		_, err := c.UserIdMapper.Delete(ctx, cred)
+		return err
+	},
+	key:  func(m *UserIdMapper) string { return m.Credential },
+	name: func(m *UserIdMapper) string { return "" },
+	match: func(desired *UserIdMapper, live []*UserIdMapper) (*UserIdMapper, error) {
+		for _, m := range live {
+			if m.Credential == desired.Credential {
+				return m, nil
+			}
+		}
+		return nil, nil
+	},
+	merge: func(desired, live *UserIdMapper) {
+		desired.Kind = live.Kind
+		if desired.APIVersion == "" {
+			desired.APIVersion = live.APIVersion
+		}
+		desired.ObjVersion = live.ObjVersion
+		desired.Created, desired.Updated = live.Created, live.Updated
+	},
+}
+
+// applyKind applies the desired objects of a kind in the namespace of c.
+func applyKind[T any](ctx context.Context, c *Client, a *applier[T], objs []desired[T], opt *ApplyOptions) ([]ApplyChange, error) {
+	live, err := a.list(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	ns := namespaceOf(c.BaseURL)
+
+	var plan []ApplyChange
+	matched := map[*T]bool{}
+	for _, d := range objs {
+		obj := *d.obj
+		change := ApplyChange{Kind: a.kind, Namespace: ns, Key: a.key(&obj), Name: a.name(&obj), Source: d.source}
+
+		l, err := a.match(&obj, live)
+		if err != nil {
+			return plan, fmt.Errorf("%s: %w", d.source, err)
+		}
+		switch {
+		case l == nil:
+			change.Action = ApplyCreate
+			if !opt.DryRun {
+				if err := a.create(ctx, c, &obj); err != nil {
+					return plan, fmt.Errorf("%s: %w", d.source, err)
+				}
+			}
+		case matched[l]:
+			return plan, fmt.Errorf("%s: %s %s/%s is declared twice", d.source, a.kind, ns, displayKey(a.kind, a.key(l)))
+		default:
+			matched[l] = true
+			a.merge(&obj, l)
+			change.Key = a.key(&obj)
+
+			patch, err := CreateMergePatch(l, &obj)
+			if err != nil {
+				return plan, err
+			}
+			if len(patch) == 0 {
+				change.Action = ApplyUnchanged
+				break
+			}
+			change.Action = ApplyReplace
+			change.Fields = patchPaths("", patch)
+			if !opt.DryRun {
+				if err := a.replace(ctx, c, change.Key, &obj); err != nil {
+					return plan, fmt.Errorf("%s: %w", d.source, err)
+				}
+			}
+		}
+		plan = append(plan, change)
+	}
+
+	if opt.Prune {
+		for _, l := range live {
+			if matched[l] || a.keep != nil && a.keep(l, live) {
+				continue
+			}
+			change := ApplyChange{Action: ApplyDelete, Kind: a.kind, Namespace: ns, Key: a.key(l), Name: a.name(l)}
+			if !opt.DryRun {
+				if err := a.delete(ctx, c, change.Key); err != nil {
+					return plan, err
+				}
+			}
+			plan = append(plan, change)
+		}
+	}
+	return plan, nil
+}
+
+// patchPaths returns the sorted paths of the members changed by patch,
+// without their values, which may be secret.
+func patchPaths(prefix string, patch map[string]interface{}) []string {
+	var paths []string
+	for k, v := range patch {
+		if sub, ok := v.(map[string]interface{}); ok && len(sub) > 0 {
+			paths = append(paths, patchPaths(prefix+k+".", sub)...)
+			continue
+		}
+		paths = append(paths, prefix+k)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,277 @@
+package prclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const applyManifests = `
+kind: PrToken
+metadata:
+  name: ci
+  site: github.com
+active: true
+---
+# The secret is kept on the server.
+kind: prToken
+metadata:
+  uid: "1"
+  name: deploy
+  site: gitlab.com
+active: true
+---
+[{"kind": "prUserIdMapper", "login": "alice", "userUUID": "u1", "active": "true"},
+ {"kind": "prUserIdMapper", "login": "bob", "userUUID": "u2", "active": "true"}]
+`
+
+func TestDecodeManifests(t *testing.T) {
+	ms, err := DecodeManifests(strings.NewReader(applyManifests), "m.yaml")
+	if err != nil {
+		t.Fatalf("DecodeManifests returned error: %v", err)
+	}
+
+	var got []string
+	for _, m := range ms {
+		got = append(got, m.Source+" "+m.Kind)
+	}
+	want := []string{"m.yaml#1 PrToken", "m.yaml#2 prToken", "m.yaml#3[0] prUserIdMapper", "m.yaml#3[1] prUserIdMapper"}
+	if !cmp.Equal(got, want) {
+		t.Errorf("DecodeManifests returned %q, want %q", got, want)
+	}
+	if ms[1].Token == nil || ms[1].Token.Metadata.UID != "1" {
+		t.Errorf("DecodeManifests returned token %+v", ms[1].Token)
+	}
+	if ms[3].UserIdMapper == nil || ms[3].UserIdMapper.UserUUID != "u2" {
+		t.Errorf("DecodeManifests returned mapper %+v", ms[3].UserIdMapper)
+	}
+}
+
+func TestDecodeManifests_errors(t *testing.T) {
+	tests := []struct{ doc, err string }{
+		{"metadata: {name: ci}", "m.yaml#1: kind is required"},
+		{"---\nkind: prUser", `m.yaml#1: unknown kind "prUser"`},
+		{"kind: List\nitems:\n- kind: prToken\n  bogus: 1\n- kind: prToken", "m.yaml#1[0]: "},
+	}
+	for _, tt := range tests {
+		_, err := DecodeManifests(strings.NewReader(tt.doc), "m.yaml")
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("DecodeManifests(%q) returned error %v, want %q", tt.doc, err, tt.err)
+		}
+	}
+}
+
+// applyServer serves the objects of resource from objs, keyed by key, and
+// records the writes made to them.
+func applyServer[T any](mux *http.ServeMux, resource string, objs map[string]*T, key func(*T) string, writes *[]string) {
+	mux.HandleFunc("/"+resource+"LIST/", func(w http.ResponseWriter, r *http.Request) {
+		list := []*T{}
+		for _, k := range sortedKeys(objs) {
+			list = append(list, objs[k])
+		}
+		json.NewEncoder(w).Encode(list)
+	})
+	mux.HandleFunc("/"+resource+"/", func(w http.ResponseWriter, r *http.Request) {
+		k := strings.TrimPrefix(r.URL.Path, "/"+resource+"/")
+		*writes = append(*writes, r.Method+" "+resource+"/"+k)
+
+		v := new(T)
+		switch r.Method {
+		case "POST", "PUT":
+			json.NewDecoder(r.Body).Decode(v)
+			objs[key(v)] = v
+		case "DELETE":
+			delete(objs, k)
+		}
+		json.NewEncoder(w).Encode(v)
+	})
+}
+
+func TestClient_Apply(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	created := &Timestamp{time.Date(2020, time.May, 1, 12, 0, 0, 0, time.UTC)}
+	tokens := map[string]*Token{
+		"1": {Kind: "prToken", Metadata: Metadata{UID: "1", Name: "deploy", Site: "github.com", Token: "s3cret"}, Active: true, Created: created},
+		"2": {Kind: "prToken", Metadata: Metadata{UID: "2", Name: "old"}},
+	}
+	mappers := map[string]*UserIdMapper{
+		"alice": {Kind: "prUserIdMapper", Credential: "alice", UserUUID: "u1", Active: "true", ObjVersion: "3"},
+		"carol": {Kind: "prUserIdMapper", Credential: "carol"},
+	}
+	var writes []string
+	applyServer(mux, tokenResource, tokens, func(t *Token) string {
+		if t.Metadata.UID == "" {
+			return "3"
+		}
+		return t.Metadata.UID
+	}, &writes)
+	applyServer(mux, mapperResource, mappers, func(m *UserIdMapper) string { return m.Credential }, &writes)
+
+	ms, err := DecodeManifests(strings.NewReader(applyManifests), "m.yaml")
+	if err != nil {
+		t.Fatalf("DecodeManifests returned error: %v", err)
+	}
+
+	ns := "pavedroad.io"
+	wantPlan := []ApplyChange{
+		{Action: ApplyCreate, Kind: TokenKind, Namespace: ns, Name: "ci", Source: "m.yaml#1"},
+		{Action: ApplyReplace, Kind: TokenKind, Namespace: ns, Key: "1", Name: "deploy", Fields: []string{"metadata.site"}, Source: "m.yaml#2"},
+		{Action: ApplyDelete, Kind: TokenKind, Namespace: ns, Key: "2", Name: "old"},
+		{Action: ApplyUnchanged, Kind: UserIdMapperKind, Namespace: ns, Key: "alice", Source: "m.yaml#3[0]"},
+		{Action: ApplyCreate, Kind: UserIdMapperKind, Namespace: ns, Key: "bob", Source: "m.yaml#3[1]"},
+		{Action: ApplyDelete, Kind: UserIdMapperKind, Namespace: ns, Key: "carol"},
+	}
+
+	plan, err := client.Apply(context.Background(), ms, &ApplyOptions{DryRun: true, Prune: true})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if !cmp.Equal(plan, wantPlan) {
+		t.Errorf("Apply returned plan %v, want %v", plan, wantPlan)
+	}
+	if len(writes) != 0 {
+		t.Errorf("Apply with DryRun made writes %q", writes)
+	}
+
+	plan, err = client.Apply(context.Background(), ms, &ApplyOptions{Prune: true})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if !cmp.Equal(plan, wantPlan) {
+		t.Errorf("Apply returned plan %v, want %v", plan, wantPlan)
+	}
+	wantWrites := []string{
+		"POST prTokens/", "PUT prTokens/1", "DELETE prTokens/2",
+		"POST prUserIdMappers/", "DELETE prUserIdMappers/carol",
+	}
+	if !cmp.Equal(writes, wantWrites) {
+		t.Errorf("Apply made writes %q, want %q", writes, wantWrites)
+	}
+	if tok := tokens["1"]; tok.Metadata.Site != "gitlab.com" || tok.Metadata.Token != "s3cret" || !tok.Created.Equal(*created) {
+		t.Errorf("Apply replaced token with site %q, secret %q and creation time %v, want the secret and creation time kept",
+			tok.Metadata.Site, tok.Metadata.Token, tok.Created)
+	}
+
+	// Applying again finds nothing to change.
+	writes = nil
+	plan, err = client.Apply(context.Background(), ms, &ApplyOptions{Prune: true})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	for _, c := range plan {
+		if c.Action != ApplyUnchanged {
+			t.Errorf("second Apply planned %v", c)
+		}
+	}
+	if len(writes) != 0 {
+		t.Errorf("second Apply made writes %q", writes)
+	}
+}
+
+func TestClient_Apply_declaredTwice(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	var writes []string
+	tokens := map[string]*Token{"1": {Metadata: Metadata{UID: "1", Name: "ci"}}}
+	applyServer(mux, tokenResource, tokens, func(t *Token) string { return t.Metadata.UID }, &writes)
+
+	ms := []Manifest{
+		{Token: &Token{Metadata: Metadata{Name: "ci"}}, Source: "a"},
+		{Token: &Token{Metadata: Metadata{UID: "1"}}, Source: "b"},
+	}
+	_, err := client.Apply(context.Background(), ms, nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "b: ") {
+		t.Errorf("Apply returned error %v, want the second manifest reported", err)
+	}
+}
+
+func TestClient_Apply_afterRotate(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	tokens := map[string]*Token{"1": {Metadata: Metadata{UID: "1", Name: "deploy", Site: "github.com", Token: "old"}, Active: true}}
+	rotationServer(mux, tokens, "")
+	mux.HandleFunc("/"+tokenResourceList+"/", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]*Token{tokens["1"], tokens["2"]})
+	})
+
+	if _, err := client.Token.Rotate(context.Background(), "1", "new", nil); err != nil {
+		t.Fatalf("Tokens.Rotate returned error: %v", err)
+	}
+
+	ms := []Manifest{{Token: &Token{Metadata: Metadata{Name: "deploy", Site: "gitlab.com"}, Active: true}, Source: "m.yaml#1"}}
+	plan, err := client.Apply(context.Background(), ms, &ApplyOptions{Prune: true})
+	if err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	want := []ApplyChange{
+		{Action: ApplyReplace, Kind: TokenKind, Namespace: "pavedroad.io", Key: "2", Name: "deploy", Fields: []string{"metadata.site"}, Source: "m.yaml#1"},
+	}
+	if !cmp.Equal(plan, want) {
+		t.Errorf("Apply returned plan %v, want %v", plan, want)
+	}
+	if _, ok := tokens["1"]; !ok {
+		t.Error("Apply pruned the rotated token")
+	}
+	if md := tokens["2"].Metadata; md.Site != "gitlab.com" || md.Token != "new" || md.RotatedFrom != "1" {
+		t.Errorf("new token is %+v, want it updated", md)
+	}
+}
+
+func TestClient_Apply_ambiguousName(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	var writes []string
+	tokens := map[string]*Token{
+		"1": {Metadata: Metadata{UID: "1", Name: "ci"}, Active: true},
+		"2": {Metadata: Metadata{UID: "2", Name: "ci"}, Active: true},
+	}
+	applyServer(mux, tokenResource, tokens, func(t *Token) string { return t.Metadata.UID }, &writes)
+
+	ms := []Manifest{{Token: &Token{Metadata: Metadata{Name: "ci"}}, Source: "a"}}
+	if _, err := client.Apply(context.Background(), ms, nil); err == nil {
+		t.Error("Expected error to be returned for a name shared by two active tokens.")
+	}
+	if len(writes) != 0 {
+		t.Errorf("Apply made writes %q", writes)
+	}
+}
+
+func TestClient_Apply_mapperDeclaredTwice(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	var writes []string
+	mappers := map[string]*UserIdMapper{"jdoe": {Credential: "jdoe"}}
+	applyServer(mux, mapperResource, mappers, func(m *UserIdMapper) string { return m.Credential }, &writes)
+
+	ms := []Manifest{
+		{UserIdMapper: &UserIdMapper{Credential: "jdoe"}, Source: "a"},
+		{UserIdMapper: &UserIdMapper{Credential: "jdoe"}, Source: "b"},
+	}
+	_, err := client.Apply(context.Background(), ms, nil)
+	if err == nil || strings.Contains(err.Error(), "jdoe") {
+		t.Errorf("Apply returned error %v, want one without the credential", err)
+	}
+}
+
+func TestApplyChange_String(t *testing.T) {
+	c := ApplyChange{Action: ApplyReplace, Kind: TokenKind, Namespace: "ns", Key: "1", Name: "ci", Fields: []string{"active", "metadata.site"}}
+	if got, want := c.String(), "PrToken ns/ci (1): replace active, metadata.site"; got != want {
+		t.Errorf("ApplyChange.String() = %q, want %q", got, want)
+	}
+
+	c = ApplyChange{Action: ApplyCreate, Kind: UserIdMapperKind, Namespace: "ns", Key: "jdoe"}
+	if got, want := c.String(), "prUserIdMapper ns/sha256:d30a5f57532a: create"; got != want {
+		t.Errorf("ApplyChange.String() = %q, want %q", got, want)
+	}
+}
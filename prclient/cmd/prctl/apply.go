@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"clients/prclient"
+	"context"
+	"fmt"
+)
+
+// apply applies the manifests of the -f file and prints the changes.
+func (cmd *command) apply(ctx context.Context) error {
+	b, err := cmd.readFile()
+	if err != nil {
+		return err
+	}
+	manifests, err := prclient.DecodeManifests(bytes.NewReader(b), cmd.file)
+	if err != nil {
+		return err
+	}
+	for _, m := range manifests {
+		if m.Token != nil {
+			if err := (tokens{}).checkSecrets(m.Token); err != nil {
+				return fmt.Errorf("%s: %v", m.Source, err)
+			}
+		}
+	}
+
+	plan, err := cmd.client.Apply(ctx, manifests, &prclient.ApplyOptions{DryRun: cmd.dryRun, Prune: cmd.prune})
+	suffix := ""
+	if cmd.dryRun {
+		suffix = " (dry run)"
+	}
+	for _, c := range plan {
+		fmt.Fprintf(cmd.stdout, "%s%s\n", c, suffix)
+	}
+	return err
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"clients/prclient"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// edit changes an object with a JSON merge patch, read from the -f file or
+// computed from the changes made to the object in $EDITOR.
+func (cmd *command) edit(ctx context.Context) error {
+	r := cmd.resource
+
+	var patch prclient.MergePatch
+	if cmd.file != "" {
+		b, err := cmd.readFile()
+		if err != nil {
+			return err
+		}
+		if err := unmarshal(b, &patch); err != nil {
+			return fmt.Errorf("%s: %v", cmd.file, err)
+		}
+		obj := r.new()
+		if err := unmarshal(b, obj); err != nil {
+			return fmt.Errorf("%s: %v", cmd.file, err)
+		}
+		if err := r.checkSecrets(obj); err != nil {
+			return err
+		}
+	} else {
+		obj, err := r.get(ctx, cmd.client, cmd.key)
+		if err != nil {
+			return err
+		}
+		if !cmd.showSecrets {
+			obj = r.redact(obj)
+		}
+		edited, err := cmd.editInEditor(obj)
+		if err != nil {
+			return err
+		}
+		if patch, err = prclient.CreateMergePatch(obj, edited); err != nil {
+			return err
+		}
+	}
+
+	if len(patch) == 0 {
+		fmt.Fprintln(cmd.stdout, "Edit cancelled, no changes made.")
+		return nil
+	}
+	obj, err := r.patch(ctx, cmd.client, cmd.key, patch)
+	if err != nil {
+		return err
+	}
+	return cmd.print(obj)
+}
+
+// editInEditor writes obj as YAML to a temporary file, opens it in
+// $EDITOR, vi by default, and decodes the file once the editor exits.
+func (cmd *command) editInEditor(obj interface{}) (interface{}, error) {
+	b, err := yaml.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := ioutil.TempFile("", "prctl-*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	editor := strings.Fields(envOr("EDITOR", "vi"))
+	c := exec.Command(editor[0], append(editor[1:], f.Name())...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return nil, fmt.Errorf("running editor: %v", err)
+	}
+
+	if b, err = ioutil.ReadFile(f.Name()); err != nil {
+		return nil, err
+	}
+	edited := cmd.resource.new()
+	if err := unmarshal(b, edited); err != nil {
+		return nil, fmt.Errorf("edited %s: %v", cmd.resource.name(), err)
+	}
+	return edited, nil
+}
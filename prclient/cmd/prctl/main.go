@@ -0,0 +1,299 @@
+/*
+Command prctl inspects and changes PavedRoad tokens and user ID mappers.
+
+Usage:
+
+	prctl [flags] get <resource> <key>
+	prctl [flags] list <resource>
+	prctl [flags] create <resource> -f <file>
+	prctl [flags] replace <resource> <key> -f <file>
+	prctl [flags] edit <resource> <key> [-f <merge patch file>]
+	prctl [flags] delete <resource> <key>
+	prctl [flags] apply -f <file> [-dry-run] [-prune]
+
+Resources are tokens, keyed by UID, and useridmappers, keyed by
+credential. Files hold a JSON or YAML object; "-" reads standard input.
+Without -f, edit opens the object in $EDITOR and sends the changes made
+as a JSON merge patch.
+
+Apply reads YAML or JSON manifests of kind prToken and prUserIdMapper,
+separated by ---, and creates or replaces the objects that differ on the
+server. With -prune, it also deletes the objects missing from the
+manifests. Each change is printed; with -dry-run, nothing is changed.
+
+Flags:
+
+	-base-url      PavedRoad API URL, e.g. https://api.pavedroad.io/api/v1/ ($PRCTL_BASE_URL)
+	-namespace     namespace of the resources ($PRCTL_NAMESPACE, default pavedroad.io)
+	-o             output format: table, json or yaml (default table)
+	-f             file holding the object or patch to send
+	-user          user for basic authentication, the password is read from $PRCTL_PASSWORD
+	-show-secrets  print token secrets instead of REDACTED
+	-timeout       overall timeout of the command (default 30s)
+	-dry-run       apply: print the changes without making them
+	-prune         apply: delete the objects missing from the manifests
+*/
+package main
+
+import (
+	"clients/prclient"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"time"
+)
+
+const defaultNamespace = "pavedroad.io"
+
+// errUsage reports a command line error; the usage is printed with it.
+var errUsage = errors.New("usage")
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, errUsage) && !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "prctl:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// options holds the flags of a command.
+type options struct {
+	baseURL     string
+	namespace   string
+	output      string
+	user        string
+	file        string
+	showSecrets bool
+	timeout     time.Duration
+	dryRun      bool
+	prune       bool
+}
+
+// command is a parsed command line.
+type command struct {
+	options
+	verb     string
+	resource resource
+	key      string
+
+	client *prclient.Client
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+// run executes the command line args.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	cmd, err := parse(args, stderr)
+	if err != nil {
+		return err
+	}
+	cmd.stdin, cmd.stdout = stdin, stdout
+
+	ctx, cancel := context.WithTimeout(context.Background(), cmd.timeout)
+	defer cancel()
+	return cmd.run(ctx)
+}
+
+func parse(args []string, stderr io.Writer) (*command, error) {
+	cmd := &command{}
+	fs := flag.NewFlagSet("prctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&cmd.baseURL, "base-url", os.Getenv("PRCTL_BASE_URL"), "PavedRoad API `URL`")
+	fs.StringVar(&cmd.namespace, "namespace", envOr("PRCTL_NAMESPACE", defaultNamespace), "`namespace` of the resources")
+	fs.StringVar(&cmd.output, "o", "table", "output `format`: table, json or yaml")
+	fs.StringVar(&cmd.user, "user", "", "`user` for basic authentication, the password is read from $PRCTL_PASSWORD")
+	fs.StringVar(&cmd.file, "f", "", "`file` holding the object or patch to send, - for standard input")
+	fs.BoolVar(&cmd.showSecrets, "show-secrets", false, "print token secrets instead of REDACTED")
+	fs.DurationVar(&cmd.timeout, "timeout", 30*time.Second, "overall `timeout` of the command")
+	fs.BoolVar(&cmd.dryRun, "dry-run", false, "apply: print the changes without making them")
+	fs.BoolVar(&cmd.prune, "prune", false, "apply: delete the objects missing from the manifests")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: prctl [flags] get|list|create|replace|edit|delete tokens|useridmappers [key]")
+		fmt.Fprintln(stderr, "       prctl [flags] apply -f file [-dry-run] [-prune]")
+		fs.PrintDefaults()
+	}
+
+	// Accept flags both before and after the positional arguments.
+	var pos []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		pos = append(pos, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	if len(pos) > 0 && pos[0] == "apply" {
+		if err := cmd.parseApply(pos); err != nil {
+			return nil, err
+		}
+		return cmd.connect()
+	}
+	if len(pos) < 2 {
+		fs.Usage()
+		return nil, errUsage
+	}
+	cmd.verb = pos[0]
+	cmd.resource = lookupResource(pos[1])
+	if cmd.resource == nil {
+		return nil, fmt.Errorf("unknown resource %q, want tokens or useridmappers", pos[1])
+	}
+
+	wantKey := cmd.verb != "list" && cmd.verb != "create"
+	switch {
+	case !containsString([]string{"get", "list", "create", "replace", "edit", "delete"}, cmd.verb):
+		return nil, fmt.Errorf("unknown command %q", cmd.verb)
+	case wantKey && len(pos) != 3:
+		return nil, fmt.Errorf("%s %s: a single key is required", cmd.verb, pos[1])
+	case !wantKey && len(pos) != 2:
+		return nil, fmt.Errorf("%s %s: unexpected arguments %q", cmd.verb, pos[1], pos[2:])
+	case (cmd.verb == "create" || cmd.verb == "replace") && cmd.file == "":
+		return nil, fmt.Errorf("%s %s: -f is required", cmd.verb, pos[1])
+	case cmd.output != "table" && cmd.output != "json" && cmd.output != "yaml":
+		return nil, fmt.Errorf("unknown output format %q, want table, json or yaml", cmd.output)
+	}
+	if wantKey {
+		cmd.key = pos[2]
+	}
+	return cmd.connect()
+}
+
+// parseApply checks the positional arguments of apply, which takes no
+// resource: the manifests give the kinds.
+func (cmd *command) parseApply(pos []string) error {
+	cmd.verb = pos[0]
+	switch {
+	case len(pos) != 1:
+		return fmt.Errorf("apply: unexpected arguments %q", pos[1:])
+	case cmd.file == "":
+		return errors.New("apply: -f is required")
+	}
+	return nil
+}
+
+// connect sets the client of cmd and returns cmd.
+func (cmd *command) connect() (*command, error) {
+	client, err := newClient(cmd.options)
+	if err != nil {
+		return nil, err
+	}
+	cmd.client = client
+	return cmd, nil
+}
+
+// newClient returns a client for the namespace and base URL of opt.
+func newClient(opt options) (*prclient.Client, error) {
+	var client *prclient.Client
+	if opt.user != "" {
+		tp := &prclient.BasicAuthTransport{Username: opt.user, Password: os.Getenv("PRCTL_PASSWORD")}
+		client = prclient.NewClient(tp.Client())
+	} else {
+		client = prclient.NewClient(nil)
+	}
+
+	if opt.baseURL != "" {
+		u, err := url.Parse(opt.baseURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base URL: %v", err)
+		}
+		client.BaseURL = u
+	}
+	return client.Namespace(opt.namespace), nil
+}
+
+func (cmd *command) run(ctx context.Context) error {
+	r := cmd.resource
+	switch cmd.verb {
+	case "get":
+		obj, err := r.get(ctx, cmd.client, cmd.key)
+		if err != nil {
+			return err
+		}
+		return cmd.print(obj)
+	case "list":
+		objs, err := r.list(ctx, cmd.client)
+		if err != nil {
+			return err
+		}
+		return cmd.print(objs...)
+	case "create":
+		obj, err := cmd.readObject()
+		if err != nil {
+			return err
+		}
+		created, err := r.create(ctx, cmd.client, obj)
+		if err != nil {
+			return err
+		}
+		return cmd.print(created)
+	case "replace":
+		obj, err := cmd.readObject()
+		if err != nil {
+			return err
+		}
+		replaced, err := r.replace(ctx, cmd.client, cmd.key, obj)
+		if err != nil {
+			return err
+		}
+		return cmd.print(replaced)
+	case "edit":
+		return cmd.edit(ctx)
+	case "apply":
+		return cmd.apply(ctx)
+	case "delete":
+		if err := r.delete(ctx, cmd.client, cmd.key); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.stdout, "%s %q deleted\n", r.name(), cmd.key)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", cmd.verb)
+}
+
+// readObject decodes the object given with -f.
+func (cmd *command) readObject() (interface{}, error) {
+	b, err := cmd.readFile()
+	if err != nil {
+		return nil, err
+	}
+	obj := cmd.resource.new()
+	if err := unmarshal(b, obj); err != nil {
+		return nil, fmt.Errorf("%s: %v", cmd.file, err)
+	}
+	if err := cmd.resource.checkSecrets(obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (cmd *command) readFile() ([]byte, error) {
+	if cmd.file == "-" {
+		return ioutil.ReadAll(cmd.stdin)
+	}
+	return ioutil.ReadFile(cmd.file)
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+	return def
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"clients/prclient"
+	"clients/prclienttest"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// prctl runs the command line args against srv and returns its output.
+func prctl(t *testing.T, srv *prclienttest.Server, stdin string, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-base-url", srv.URL + "/api/v1/"}, args...)
+	err := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return stdout.String() + stderr.String(), err
+}
+
+func TestPrctl_tokens(t *testing.T) {
+	srv := prclienttest.NewServer()
+	defer srv.Close()
+
+	out, err := prctl(t, srv, `{"metadata": {"name": "gh", "site": "github", "token": "s3cr3t"}, "active": true}`,
+		"create", "tokens", "-f", "-", "-o", "json")
+	if err != nil {
+		t.Fatalf("create returned error: %v\n%s", err, out)
+	}
+	if strings.Contains(out, "s3cr3t") || !strings.Contains(out, `"token": "REDACTED"`) {
+		t.Errorf("create printed the secret:\n%s", out)
+	}
+	uid := srv.Tokens(prclienttest.DefaultNamespace)[0].Metadata.UID
+
+	out, err = prctl(t, srv, "", "list", "tokens")
+	if err != nil {
+		t.Fatalf("list returned error: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 2 ||
+		!strings.HasPrefix(lines[0], "UID") || !strings.Contains(lines[1], uid) || !strings.Contains(lines[1], "github") {
+		t.Errorf("list printed:\n%s", out)
+	}
+
+	out, err = prctl(t, srv, "", "get", "tokens", uid, "-o", "yaml", "-show-secrets")
+	if err != nil {
+		t.Fatalf("get returned error: %v", err)
+	}
+	if !strings.Contains(out, "token: s3cr3t") {
+		t.Errorf("get -show-secrets printed:\n%s", out)
+	}
+
+	if out, err = prctl(t, srv, "metadata:\n  site: gitlab\n", "edit", "tokens", uid, "-f", "-"); err != nil {
+		t.Fatalf("edit returned error: %v\n%s", err, out)
+	}
+	if tok, _ := srv.Token(prclienttest.DefaultNamespace, uid); tok.Metadata.Site != "gitlab" || tok.Metadata.Token != "s3cr3t" {
+		t.Errorf("edited token is %+v", tok)
+	}
+
+	if _, err = prctl(t, srv, "metadata:\n  token: REDACTED\n", "replace", "tokens", uid, "-f", "-"); err == nil {
+		t.Error("Expected error to be returned for a REDACTED secret.")
+	}
+
+	if _, err = prctl(t, srv, "", "delete", "tokens", uid); err != nil {
+		t.Fatalf("delete returned error: %v", err)
+	}
+	if _, ok := srv.Token(prclienttest.DefaultNamespace, uid); ok {
+		t.Error("token was not deleted")
+	}
+}
+
+func TestPrctl_useridmappersNamespace(t *testing.T) {
+	srv := prclienttest.NewServer()
+	defer srv.Close()
+	srv.AddUserIdMapper("acme", prclient.UserIdMapper{Credential: "jdoe", LoginCount: 3})
+
+	dir, err := ioutil.TempDir("", "prctl-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "mapper.yaml")
+	ioutil.WriteFile(file, []byte("login: jdoe\nloginCount: 4\nobjVersion: \"1\"\n"), 0600)
+
+	if out, err := prctl(t, srv, "", "-namespace", "acme", "replace", "useridmappers", "jdoe", "-f", file); err != nil {
+		t.Fatalf("replace returned error: %v\n%s", err, out)
+	}
+	if m, _ := srv.UserIdMapper("acme", "jdoe"); m.LoginCount != 4 {
+		t.Errorf("replaced mapper is %+v", m)
+	}
+
+	// The version read is now stale.
+	if _, err := prctl(t, srv, "", "-namespace", "acme", "replace", "useridmappers", "jdoe", "-f", file); err == nil {
+		t.Error("Expected error to be returned for a stale version.")
+	}
+
+	out, err := prctl(t, srv, "", "-namespace", "acme", "list", "useridmappers", "-o", "json")
+	if err != nil || !strings.Contains(out, `"login": "jdoe"`) {
+		t.Errorf("list returned %v:\n%s", err, out)
+	}
+	out, err = prctl(t, srv, "", "list", "useridmappers")
+	if err != nil || !strings.Contains(out, "No useridmappers found") {
+		t.Errorf("list in the default namespace returned %v:\n%s", err, out)
+	}
+}
+
+func TestPrctl_apply(t *testing.T) {
+	srv := prclienttest.NewServer()
+	defer srv.Close()
+	old := srv.AddToken(prclienttest.DefaultNamespace, prclient.Token{Metadata: prclient.Metadata{Name: "old"}})
+	srv.AddUserIdMapper(prclienttest.DefaultNamespace, prclient.UserIdMapper{Credential: "jdoe", LoginCount: 3})
+
+	manifests := `kind: prToken
+metadata:
+  name: gh
+  site: github
+  token: s3cr3t
+active: true
+---
+kind: prUserIdMapper
+login: jdoe
+loginCount: 4
+`
+	out, err := prctl(t, srv, manifests, "apply", "-f", "-", "-dry-run", "-prune")
+	if err != nil {
+		t.Fatalf("apply -dry-run returned error: %v\n%s", err, out)
+	}
+	want := "PrToken pavedroad.io/gh: create (dry run)\n" +
+		"PrToken pavedroad.io/old (" + old.Metadata.UID + "): delete (dry run)\n" +
+		"prUserIdMapper pavedroad.io/sha256:d30a5f57532a: replace loginCount (dry run)\n"
+	if out != want {
+		t.Errorf("apply -dry-run printed:\n%s\nwant:\n%s", out, want)
+	}
+	if len(srv.Tokens(prclienttest.DefaultNamespace)) != 1 {
+		t.Error("apply -dry-run changed the tokens")
+	}
+
+	if out, err = prctl(t, srv, manifests, "apply", "-f", "-", "-prune"); err != nil {
+		t.Fatalf("apply returned error: %v\n%s", err, out)
+	}
+	if strings.Contains(out, "s3cr3t") {
+		t.Errorf("apply printed the secret:\n%s", out)
+	}
+	if toks := srv.Tokens(prclienttest.DefaultNamespace); len(toks) != 1 || toks[0].Metadata.Name != "gh" {
+		t.Errorf("applied tokens are %+v", toks)
+	}
+	if m, _ := srv.UserIdMapper(prclienttest.DefaultNamespace, "jdoe"); m.LoginCount != 4 {
+		t.Errorf("applied mapper is %+v", m)
+	}
+
+	out, err = prctl(t, srv, manifests, "apply", "-f", "-")
+	if err != nil || strings.Count(out, ": unchanged\n") != 2 {
+		t.Errorf("second apply returned %v:\n%s", err, out)
+	}
+}
+
+func TestPrctl_usage(t *testing.T) {
+	srv := prclienttest.NewServer()
+	defer srv.Close()
+
+	for _, args := range [][]string{
+		{},
+		{"get", "tokens"},
+		{"list", "widgets"},
+		{"frobnicate", "tokens", "1"},
+		{"create", "tokens"},
+		{"get", "tokens", "1", "-o", "xml"},
+		{"apply"},
+		{"apply", "tokens", "-f", "-"},
+	} {
+		if _, err := prctl(t, srv, "", args...); err == nil {
+			t.Errorf("prctl %q returned no error", args)
+		}
+	}
+}
+
+func TestPrctl_editInEditor(t *testing.T) {
+	srv := prclienttest.NewServer()
+	defer srv.Close()
+	tok := srv.AddToken(prclienttest.DefaultNamespace, prclient.Token{Metadata: prclient.Metadata{Site: "github", Token: "s3cr3t"}})
+
+	t.Setenv("EDITOR", "sed -i s/github/gitlab/")
+	if out, err := prctl(t, srv, "", "edit", "tokens", tok.Metadata.UID); err != nil {
+		t.Fatalf("edit returned error: %v\n%s", err, out)
+	}
+	got, _ := srv.Token(prclienttest.DefaultNamespace, tok.Metadata.UID)
+	if got.Metadata.Site != "gitlab" || got.Metadata.Token != "s3cr3t" {
+		t.Errorf("edited token is %+v", got)
+	}
+
+	t.Setenv("EDITOR", "true")
+	out, err := prctl(t, srv, "", "edit", "tokens", tok.Metadata.UID)
+	if err != nil || !strings.Contains(out, "no changes made") {
+		t.Errorf("edit without changes returned %v:\n%s", err, out)
+	}
+}
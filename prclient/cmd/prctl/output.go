@@ -0,0 +1,70 @@
+package main
+
+import (
+	"clients/prclient"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"sigs.k8s.io/yaml"
+)
+
+// print writes objs in the output format of cmd. Lists are printed as a
+// JSON array, or as YAML documents separated by ---.
+func (cmd *command) print(objs ...interface{}) error {
+	if !cmd.showSecrets {
+		for i, obj := range objs {
+			objs[i] = cmd.resource.redact(obj)
+		}
+	}
+
+	switch cmd.output {
+	case "json":
+		var v interface{} = objs
+		if cmd.verb != "list" {
+			v = objs[0]
+		}
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.stdout, "%s\n", b)
+	case "yaml":
+		for i, obj := range objs {
+			b, err := yaml.Marshal(obj)
+			if err != nil {
+				return err
+			}
+			if i > 0 {
+				fmt.Fprintln(cmd.stdout, "---")
+			}
+			cmd.stdout.Write(b)
+		}
+	default:
+		if len(objs) == 0 {
+			fmt.Fprintf(cmd.stdout, "No %ss found in namespace %s.\n", cmd.resource.name(), cmd.namespace)
+			return nil
+		}
+		tw := tabwriter.NewWriter(cmd.stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(cmd.resource.columns(), "\t"))
+		for _, obj := range objs {
+			fmt.Fprintln(tw, strings.Join(cmd.resource.row(obj), "\t"))
+		}
+		return tw.Flush()
+	}
+	return nil
+}
+
+// unmarshal decodes a JSON or YAML document into v.
+func unmarshal(b []byte, v interface{}) error {
+	return yaml.Unmarshal(b, v)
+}
+
+func formatTime(t *prclient.Timestamp) string {
+	if t == nil || t.IsZero() {
+		return "<none>"
+	}
+	return t.Format(time.RFC3339)
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"clients/prclient"
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// redacted replaces token secrets in the output unless -show-secrets is set.
+const redacted = "REDACTED"
+
+// resource is a kind of PavedRoad object handled by prctl. Objects are
+// passed around as pointers to their prclient type.
+type resource interface {
+	name() string
+	new() interface{}
+	get(ctx context.Context, c *prclient.Client, key string) (interface{}, error)
+	list(ctx context.Context, c *prclient.Client) ([]interface{}, error)
+	create(ctx context.Context, c *prclient.Client, obj interface{}) (interface{}, error)
+	replace(ctx context.Context, c *prclient.Client, key string, obj interface{}) (interface{}, error)
+	patch(ctx context.Context, c *prclient.Client, key string, p prclient.MergePatch) (interface{}, error)
+	delete(ctx context.Context, c *prclient.Client, key string) error
+
+	// columns and row give the table output.
+	columns() []string
+	row(obj interface{}) []string
+
+	// redact returns a copy of obj without its secrets.
+	redact(obj interface{}) interface{}
+
+	// checkSecrets fails if obj holds a redacted secret, as read from
+	// the output of prctl without -show-secrets.
+	checkSecrets(obj interface{}) error
+}
+
+// resources lists the resources by name and aliases.
+var resources = map[string]resource{
+	"tokens":          tokens{},
+	"token":           tokens{},
+	"prtokens":        tokens{},
+	"useridmappers":   mappers{},
+	"useridmapper":    mappers{},
+	"mappers":         mappers{},
+	"pruseridmappers": mappers{},
+}
+
+func lookupResource(name string) resource {
+	return resources[strings.ToLower(name)]
+}
+
+// tokens is the prTokens resource.
+type tokens struct{}
+
+func (tokens) name() string     { return "token" }
+func (tokens) new() interface{} { return new(prclient.Token) }
+
+func (tokens) get(ctx context.Context, c *prclient.Client, key string) (interface{}, error) {
+	t, _, err := c.Token.Get(ctx, key)
+	return t, err
+}
+
+func (tokens) list(ctx context.Context, c *prclient.Client) ([]interface{}, error) {
+	ts, err := c.Token.ListAll(ctx, nil)
+	objs := make([]interface{}, len(ts))
+	for i, t := range ts {
+		objs[i] = t
+	}
+	return objs, err
+}
+
+func (tokens) create(ctx context.Context, c *prclient.Client, obj interface{}) (interface{}, error) {
+	t, _, err := c.Token.Create(ctx, *obj.(*prclient.Token))
+	return t, err
+}
+
+func (tokens) replace(ctx context.Context, c *prclient.Client, key string, obj interface{}) (interface{}, error) {
+	t, _, err := c.Token.Replace(ctx, obj.(*prclient.Token), key)
+	return t, err
+}
+
+func (tokens) patch(ctx context.Context, c *prclient.Client, key string, p prclient.MergePatch) (interface{}, error) {
+	t, _, err := c.Token.Patch(ctx, key, p)
+	return t, err
+}
+
+func (tokens) delete(ctx context.Context, c *prclient.Client, key string) error {
+	_, err := c.Token.Delete(ctx, key)
+	return err
+}
+
+func (tokens) columns() []string {
+	return []string{"UID", "NAME", "SITE", "ENDPOINT", "ACTIVE", "EXPIRES"}
+}
+
+func (tokens) row(obj interface{}) []string {
+	t := obj.(*prclient.Token)
+	return []string{t.Metadata.UID, t.Metadata.Name, t.Metadata.Site, t.Metadata.EndPoint,
+		strconv.FormatBool(t.Active), formatTime(t.ExpiresAt)}
+}
+
+func (tokens) redact(obj interface{}) interface{} {
+	t := *obj.(*prclient.Token)
+	if t.Metadata.Token != "" {
+		t.Metadata.Token = redacted
+	}
+	return &t
+}
+
+func (tokens) checkSecrets(obj interface{}) error {
+	if obj.(*prclient.Token).Metadata.Token == redacted {
+		return errors.New("the token secret is REDACTED; read the token with -show-secrets or set the secret")
+	}
+	return nil
+}
+
+// mappers is the prUserIdMappers resource.
+type mappers struct{}
+
+func (mappers) name() string     { return "useridmapper" }
+func (mappers) new() interface{} { return new(prclient.UserIdMapper) }
+
+func (mappers) get(ctx context.Context, c *prclient.Client, key string) (interface{}, error) {
+	m, _, err := c.UserIdMapper.Get(ctx, key)
+	return m, err
+}
+
+func (mappers) list(ctx context.Context, c *prclient.Client) ([]interface{}, error) {
+	ms, err := c.UserIdMapper.ListAll(ctx, nil)
+	objs := make([]interface{}, len(ms))
+	for i, m := range ms {
+		objs[i] = m
+	}
+	return objs, err
+}
+
+func (mappers) create(ctx context.Context, c *prclient.Client, obj interface{}) (interface{}, error) {
+	m, _, err := c.UserIdMapper.Create(ctx, *obj.(*prclient.UserIdMapper))
+	return m, err
+}
+
+func (mappers) replace(ctx context.Context, c *prclient.Client, key string, obj interface{}) (interface{}, error) {
+	m, _, err := c.UserIdMapper.Replace(ctx, obj.(*prclient.UserIdMapper), key)
+	return m, err
+}
+
+func (mappers) patch(ctx context.Context, c *prclient.Client, key string, p prclient.MergePatch) (interface{}, error) {
+	m, _, err := c.UserIdMapper.Patch(ctx, key, p)
+	return m, err
+}
+
+func (mappers) delete(ctx context.Context, c *prclient.Client, key string) error {
+	_, err := c.UserIdMapper.Delete(ctx, key)
+	return err
+}
+
+func (mappers) columns() []string {
+	return []string{"CREDENTIAL", "USER", "LOGINS", "ACTIVE", "VERSION", "UPDATED"}
+}
+
+func (mappers) row(obj interface{}) []string {
+	m := obj.(*prclient.UserIdMapper)
+	return []string{m.Credential, m.UserUUID, strconv.Itoa(m.LoginCount), m.Active, m.ObjVersion, formatTime(m.Updated)}
+}
+
+// redact returns obj as is: the credential of a mapper is its key.
+func (mappers) redact(obj interface{}) interface{} { return obj }
+
+func (mappers) checkSecrets(obj interface{}) error { return nil }
@@ -0,0 +1,206 @@
+package prclient
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// KeyProvider wraps and unwraps the data keys used to encrypt token secrets.
+//
+// Every secret is encrypted with its own random data key (envelope
+// encryption); only the wrapped data key is stored next to the ciphertext.
+// Implementations may keep the key encryption key in a local file, like
+// LocalKeyProvider, or in an external KMS.
+type KeyProvider interface {
+	// WrapKey encrypts dataKey and returns it with the ID of the key
+	// encryption key used, which is handed back to UnwrapKey.
+	WrapKey(ctx context.Context, dataKey []byte) (wrapped []byte, keyID string, err error)
+
+	// UnwrapKey decrypts a data key returned by WrapKey.
+	UnwrapKey(ctx context.Context, wrapped []byte, keyID string) ([]byte, error)
+}
+
+// encryptedPrefix marks secrets encrypted by the client. Values without it
+// are considered plaintext and returned unchanged.
+const encryptedPrefix = "prenc:"
+
+// encryptedVersion prefixes the secrets encrypted by this client. Secrets
+// of other versions, such as v1 secrets that were not bound to a token,
+// are rejected.
+const encryptedVersion = encryptedPrefix + "v2:"
+
+const dataKeySize = 32 // AES-256
+
+var secretEncoding = base64.RawURLEncoding
+
+// encryptSecret encrypts plaintext with a new data key wrapped by kp.
+// The ciphertext is bound to aad, the additional data identifying the
+// owner of the secret, which must be given again to decryptSecret.
+// The result has the form prenc:v2:{keyID}:{wrapped key}:{nonce+ciphertext},
+// each field being base64url encoded.
+func encryptSecret(ctx context.Context, kp KeyProvider, plaintext string, aad []byte) (string, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", err
+	}
+	sealed, err := sealGCM(dataKey, []byte(plaintext), aad)
+	if err != nil {
+		return "", err
+	}
+	wrapped, keyID, err := kp.WrapKey(ctx, dataKey)
+	if err != nil {
+		return "", fmt.Errorf("wrapping data key: %w", err)
+	}
+	return encryptedVersion + secretEncoding.EncodeToString([]byte(keyID)) + ":" +
+		secretEncoding.EncodeToString(wrapped) + ":" + secretEncoding.EncodeToString(sealed), nil
+}
+
+// decryptSecret reverses encryptSecret. It fails if aad is not the
+// additional data the secret was encrypted with. Values not produced by
+// encryptSecret are returned as is.
+func decryptSecret(ctx context.Context, kp KeyProvider, value string, aad []byte) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+	if !strings.HasPrefix(value, encryptedVersion) {
+		return "", errors.New("unknown encrypted secret version")
+	}
+	parts := strings.Split(strings.TrimPrefix(value, encryptedVersion), ":")
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted secret")
+	}
+	var fields [3][]byte
+	for i, p := range parts {
+		b, err := secretEncoding.DecodeString(p)
+		if err != nil {
+			return "", fmt.Errorf("malformed encrypted secret: %w", err)
+		}
+		fields[i] = b
+	}
+
+	dataKey, err := kp.UnwrapKey(ctx, fields[1], string(fields[0]))
+	if err != nil {
+		return "", fmt.Errorf("unwrapping data key: %w", err)
+	}
+	plaintext, err := openGCM(dataKey, fields[2], aad)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// sealGCM encrypts plaintext with AES-GCM under key, authenticating aad
+// along with it, and returns the random nonce followed by the ciphertext.
+func sealGCM(key, plaintext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, aad), nil
+}
+
+// openGCM decrypts the output of sealGCM.
+func openGCM(key, sealed, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("encrypted secret too short")
+	}
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// LocalKeyProvider is a KeyProvider wrapping data keys with AES-GCM under a
+// 256-bit key read from a local file.
+type LocalKeyProvider struct {
+	key []byte
+	id  string
+}
+
+// NewLocalKeyProvider reads the key encryption key from the file at path.
+// The file holds 32 bytes, either raw or base64 encoded, as written by
+// GenerateKeyFile.
+func NewLocalKeyProvider(path string) (*LocalKeyProvider, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key := b
+	if len(b) != dataKeySize {
+		s := strings.TrimSpace(string(b))
+		if key, err = base64.StdEncoding.DecodeString(s); err != nil {
+			return nil, fmt.Errorf("key file %s: not a raw or base64 encoded key", path)
+		}
+	}
+	return newLocalKeyProvider(key)
+}
+
+func newLocalKeyProvider(key []byte) (*LocalKeyProvider, error) {
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", dataKeySize, len(key))
+	}
+	sum := sha256.Sum256(key)
+	return &LocalKeyProvider{key: key, id: "local:" + hex.EncodeToString(sum[:8])}, nil
+}
+
+// GenerateKeyFile writes a new random base64 encoded key, readable by
+// NewLocalKeyProvider, to the file at path. The file is created with
+// mode 0600 and must not already exist.
+func GenerateKeyFile(path string) error {
+	key := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, base64.StdEncoding.EncodeToString(key)+"\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// KeyID identifies the key of p by a fingerprint, so ciphertexts made with
+// another key are detected before decryption is attempted.
+func (p *LocalKeyProvider) KeyID() string { return p.id }
+
+// WrapKey implements KeyProvider.
+func (p *LocalKeyProvider) WrapKey(ctx context.Context, dataKey []byte) ([]byte, string, error) {
+	wrapped, err := sealGCM(p.key, dataKey, nil)
+	return wrapped, p.id, err
+}
+
+// UnwrapKey implements KeyProvider.
+func (p *LocalKeyProvider) UnwrapKey(ctx context.Context, wrapped []byte, keyID string) ([]byte, error) {
+	if keyID != p.id {
+		return nil, fmt.Errorf("secret was encrypted with key %q, have %q", keyID, p.id)
+	}
+	return openGCM(p.key, wrapped, nil)
+}
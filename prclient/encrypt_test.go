@@ -0,0 +1,250 @@
+package prclient
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testKeyProvider(t *testing.T) *LocalKeyProvider {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "pr-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "key")
+	if err := GenerateKeyFile(path); err != nil {
+		t.Fatalf("GenerateKeyFile returned error: %v", err)
+	}
+	kp, err := NewLocalKeyProvider(path)
+	if err != nil {
+		t.Fatalf("NewLocalKeyProvider returned error: %v", err)
+	}
+	return kp
+}
+
+func TestEncryptSecret_roundTrip(t *testing.T) {
+	kp := testKeyProvider(t)
+	ctx := context.Background()
+
+	enc, err := encryptSecret(ctx, kp, "s3cr3t", secretAAD("1"))
+	if err != nil {
+		t.Fatalf("encryptSecret returned error: %v", err)
+	}
+	if !strings.HasPrefix(enc, encryptedPrefix) || strings.Contains(enc, "s3cr3t") {
+		t.Errorf("encryptSecret returned %q", enc)
+	}
+
+	dec, err := decryptSecret(ctx, kp, enc, secretAAD("1"))
+	if err != nil {
+		t.Fatalf("decryptSecret returned error: %v", err)
+	}
+	if dec != "s3cr3t" {
+		t.Errorf("decryptSecret returned %q, want %q", dec, "s3cr3t")
+	}
+}
+
+func TestDecryptSecret_plaintext(t *testing.T) {
+	dec, err := decryptSecret(context.Background(), testKeyProvider(t), "plain", nil)
+	if err != nil || dec != "plain" {
+		t.Errorf("decryptSecret returned %q, %v, want plain, nil", dec, err)
+	}
+}
+
+func TestDecryptSecret_wrongKey(t *testing.T) {
+	ctx := context.Background()
+	enc, err := encryptSecret(ctx, testKeyProvider(t), "s3cr3t", nil)
+	if err != nil {
+		t.Fatalf("encryptSecret returned error: %v", err)
+	}
+	if _, err := decryptSecret(ctx, testKeyProvider(t), enc, nil); err == nil {
+		t.Error("Expected error to be returned for another key.")
+	}
+}
+
+func TestDecryptSecret_version(t *testing.T) {
+	kp := testKeyProvider(t)
+	ctx := context.Background()
+	enc, err := encryptSecret(ctx, kp, "s3cr3t", nil)
+	if err != nil {
+		t.Fatalf("encryptSecret returned error: %v", err)
+	}
+	v1 := encryptedPrefix + "v1:" + strings.TrimPrefix(enc, encryptedVersion)
+	if _, err := decryptSecret(ctx, kp, v1, nil); err == nil {
+		t.Error("Expected error to be returned for a v1 secret.")
+	}
+}
+
+func TestDecryptSecret_otherToken(t *testing.T) {
+	kp := testKeyProvider(t)
+	ctx := context.Background()
+	enc, err := encryptSecret(ctx, kp, "s3cr3t", secretAAD("1"))
+	if err != nil {
+		t.Fatalf("encryptSecret returned error: %v", err)
+	}
+	if _, err := decryptSecret(ctx, kp, enc, secretAAD("2")); err == nil {
+		t.Error("Expected error to be returned for a secret of another token.")
+	}
+}
+
+func TestNewLocalKeyProvider_badKey(t *testing.T) {
+	file, dir, err := openTestFile("key", "too short")
+	if err != nil {
+		t.Fatalf("Unable to create temp file: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	file.Close()
+
+	if _, err := NewLocalKeyProvider(file.Name()); err == nil {
+		t.Error("Expected error to be returned for an invalid key file.")
+	}
+}
+
+func TestTokensService_Create_encrypted(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+	kp := testKeyProvider(t)
+	client.KeyProvider = kp
+
+	mux.HandleFunc("/"+tokenResource+"/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		var v Token
+		json.NewDecoder(r.Body).Decode(&v)
+		secret, err := decryptSecret(context.Background(), kp, v.Metadata.Token, secretAAD(v.Metadata.UID))
+		if v.Metadata.UID == "" || err != nil || secret != "s3cr3t" {
+			t.Errorf("Request body token = %q, want a secret encrypted for UID %q", v.Metadata.Token, v.Metadata.UID)
+		}
+		json.NewEncoder(w).Encode(v)
+	})
+
+	for _, uid := range []string{"1", ""} {
+		input := Token{Metadata: Metadata{UID: uid, Token: "s3cr3t"}}
+		token, _, err := client.Token.Create(context.Background(), input)
+		if err != nil {
+			t.Fatalf("Tokens.Create returned error: %v", err)
+		}
+		if token.Metadata.Token != "s3cr3t" {
+			t.Errorf("Tokens.Create returned token %q, want %q", token.Metadata.Token, "s3cr3t")
+		}
+		if uid != "" && token.Metadata.UID != uid {
+			t.Errorf("Tokens.Create returned UID %q, want %q", token.Metadata.UID, uid)
+		}
+	}
+}
+
+func TestTokensService_List_decrypted(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+	kp := testKeyProvider(t)
+	client.KeyProvider = kp
+
+	enc, err := encryptSecret(context.Background(), kp, "s3cr3t", secretAAD("1"))
+	if err != nil {
+		t.Fatalf("encryptSecret returned error: %v", err)
+	}
+	mux.HandleFunc("/"+tokenResourceList+"/", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]Token{
+			{Metadata: Metadata{UID: "1", Name: "ci", Token: enc}},
+			{Metadata: Metadata{UID: "2", Token: "legacy"}},
+		})
+	})
+
+	tokens, _, err := client.Token.List(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Tokens.List returned error: %v", err)
+	}
+	if got := tokens[0].Metadata.Token + "," + tokens[1].Metadata.Token; got != "s3cr3t,legacy" {
+		t.Errorf("Tokens.List returned secrets %q, want %q", got, "s3cr3t,legacy")
+	}
+}
+
+// findSecret returns the first encrypted secret found in v, a decoded JSON
+// value.
+func findSecret(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		if strings.HasPrefix(v, encryptedPrefix) {
+			return v
+		}
+	case []interface{}:
+		for _, e := range v {
+			if s := findSecret(e); s != "" {
+				return s
+			}
+		}
+	case map[string]interface{}:
+		for _, e := range v {
+			if s := findSecret(e); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func TestTokensService_Patch_encrypted(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+	kp := testKeyProvider(t)
+	client.KeyProvider = kp
+	ctx := context.Background()
+
+	var wantSecret string
+	mux.HandleFunc("/"+tokenResource+"/1", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "PATCH")
+		b, _ := ioutil.ReadAll(r.Body)
+		var v interface{}
+		json.Unmarshal(b, &v)
+		enc := findSecret(v)
+		if wantSecret == "" {
+			if enc != "" {
+				t.Errorf("Request body = %s, want no secret", b)
+			}
+		} else if secret, err := decryptSecret(ctx, kp, enc, secretAAD("1")); strings.Contains(string(b), wantSecret) || err != nil || secret != wantSecret {
+			t.Errorf("Request body = %s, want secret %q encrypted for token 1", b, wantSecret)
+		}
+		w.Write([]byte(blankTokenJSON))
+	})
+
+	tests := []struct {
+		name  string
+		patch PatchDocument
+		// The secret the patch is expected to send, if any.
+		wantSecret string
+	}{
+		{"merge map", MergePatch{"metadata": map[string]interface{}{"token": "s3cr3t"}}, "s3cr3t"},
+		{"merge nested", MergePatch{"metadata": MergePatch{"token": "s3cr3t", "name": "cd"}}, "s3cr3t"},
+		{"merge struct", MergePatch{"metadata": Metadata{Name: "cd", Token: "s3cr3t"}}, "s3cr3t"},
+		{"merge rename", MergePatch{"metadata": map[string]string{"name": "cd"}}, ""},
+		{"json token", JSONPatch{{Op: "replace", Path: "/metadata/token", Value: "s3cr3t"}}, "s3cr3t"},
+		{"json metadata", JSONPatch{{Op: "add", Path: "/metadata", Value: Metadata{Name: "cd", Token: "s3cr3t"}}}, "s3cr3t"},
+		{"json rename", JSONPatch{{Op: "replace", Path: "/metadata/name", Value: "cd"}}, ""},
+	}
+	for _, tt := range tests {
+		wantSecret = tt.wantSecret
+		if _, _, err := client.Token.Patch(ctx, "1", tt.patch); err != nil {
+			t.Errorf("Tokens.Patch(%s) returned error: %v", tt.name, err)
+		}
+	}
+}
+
+func TestTokensService_Patch_encryptedMove(t *testing.T) {
+	client, _, _, teardown := setup()
+	defer teardown()
+	client.KeyProvider = testKeyProvider(t)
+
+	patch := JSONPatch{{Op: "copy", From: "/metadata/site", Path: "/metadata/token"}}
+	if _, _, err := client.Token.Patch(context.Background(), "1", patch); err == nil {
+		t.Error("Expected error to be returned for a secret copied from another member.")
+	}
+	if _, _, err := client.Token.Patch(context.Background(), "1", nil); err == nil {
+		t.Error("Expected error to be returned for a nil patch.")
+	}
+}
@@ -0,0 +1,88 @@
+package prclient
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Sentinel errors classifying PavedRoad API errors. They are matched with
+// errors.Is by *ErrorResponse, according to the HTTP status code, by the
+// *Error entries of an ErrorResponse, according to their code, and by
+// *RateLimitError:
+//
+//	if errors.Is(err, prclient.ErrNotFound) {
+//		// create the token instead
+//	}
+var (
+	ErrBadRequest   = errors.New("prclient: bad request")
+	ErrUnauthorized = errors.New("prclient: unauthorized")
+	ErrForbidden    = errors.New("prclient: forbidden")
+	ErrNotFound     = errors.New("prclient: not found")
+	ErrConflict     = errors.New("prclient: conflict")
+	ErrValidation   = errors.New("prclient: validation failed")
+	ErrRateLimited  = errors.New("prclient: rate limited")
+	ErrServer       = errors.New("prclient: server error")
+)
+
+// statusError returns the sentinel error matching an HTTP status code, or nil.
+func statusError(code int) error {
+	switch code {
+	case http.StatusBadRequest:
+		return ErrBadRequest
+	case http.StatusUnauthorized:
+		return ErrUnauthorized
+	case http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusNotFound, http.StatusGone:
+		return ErrNotFound
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return ErrConflict
+	case http.StatusUnprocessableEntity:
+		return ErrValidation
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	}
+	if code >= 500 {
+		return ErrServer
+	}
+	return nil
+}
+
+// Is reports whether the status code of the response that caused r matches
+// the sentinel error target, e.g. ErrNotFound for 404 Not Found.
+func (r *ErrorResponse) Is(target error) bool {
+	return r.Response != nil && target != nil && statusError(r.Response.StatusCode) == target
+}
+
+// Unwrap returns the individual errors of r, so errors.Is and errors.As can
+// inspect them.
+func (r *ErrorResponse) Unwrap() []error {
+	if len(r.Errors) == 0 {
+		return nil
+	}
+	errs := make([]error, len(r.Errors))
+	for i := range r.Errors {
+		errs[i] = &r.Errors[i]
+	}
+	return errs
+}
+
+// Is reports whether the validation code of e matches the sentinel error
+// target: ErrNotFound for missing, ErrConflict for already_exists, and
+// ErrValidation for missing_field and invalid.
+func (e *Error) Is(target error) bool {
+	switch e.Code {
+	case "missing":
+		return target == ErrNotFound
+	case "already_exists":
+		return target == ErrConflict
+	case "missing_field", "invalid":
+		return target == ErrValidation
+	}
+	return false
+}
+
+// Is reports whether target is ErrRateLimited.
+func (r *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
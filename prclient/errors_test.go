@@ -0,0 +1,90 @@
+package prclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestErrorResponse_Is(t *testing.T) {
+	tests := []struct {
+		status int
+		want   error
+	}{
+		{http.StatusBadRequest, ErrBadRequest},
+		{http.StatusUnauthorized, ErrUnauthorized},
+		{http.StatusForbidden, ErrForbidden},
+		{http.StatusNotFound, ErrNotFound},
+		{http.StatusConflict, ErrConflict},
+		{http.StatusPreconditionFailed, ErrConflict},
+		{http.StatusUnprocessableEntity, ErrValidation},
+		{http.StatusBadGateway, ErrServer},
+	}
+	sentinels := []error{ErrBadRequest, ErrUnauthorized, ErrForbidden, ErrNotFound,
+		ErrConflict, ErrValidation, ErrRateLimited, ErrServer}
+
+	for _, tt := range tests {
+		var err error = &ErrorResponse{Response: &http.Response{StatusCode: tt.status}}
+		for _, s := range sentinels {
+			if got, want := errors.Is(err, s), s == tt.want; got != want {
+				t.Errorf("errors.Is(%d, %v) = %v, want %v", tt.status, s, got, want)
+			}
+		}
+	}
+}
+
+func TestErrorResponse_Unwrap(t *testing.T) {
+	var err error = &ErrorResponse{
+		Response: &http.Response{StatusCode: http.StatusBadRequest},
+		Errors:   []Error{{Resource: "r", Field: "f", Code: "missing_field"}},
+	}
+
+	if !errors.Is(err, ErrValidation) {
+		t.Errorf("errors.Is(%v, ErrValidation) = false, want true", err)
+	}
+
+	var e *Error
+	if !errors.As(err, &e) || e.Field != "f" {
+		t.Errorf("errors.As(%v, *Error) returned %+v", err, e)
+	}
+}
+
+func TestError_Is(t *testing.T) {
+	tests := map[string]error{
+		"missing":        ErrNotFound,
+		"already_exists": ErrConflict,
+		"invalid":        ErrValidation,
+		"missing_field":  ErrValidation,
+	}
+	for code, want := range tests {
+		if err := (&Error{Code: code}); !errors.Is(err, want) {
+			t.Errorf("errors.Is(Error{Code: %q}, %v) = false, want true", code, want)
+		}
+	}
+	if errors.Is(&Error{Code: "custom"}, ErrValidation) {
+		t.Error("errors.Is(Error{Code: custom}, ErrValidation) = true, want false")
+	}
+}
+
+func TestRateLimitError_Is(t *testing.T) {
+	var err error = &RateLimitError{}
+	if !errors.Is(err, ErrRateLimited) {
+		t.Error("errors.Is(RateLimitError, ErrRateLimited) = false, want true")
+	}
+}
+
+func TestDo_wrappedSentinel(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+
+	_, _, err := client.Token.Get(context.Background(), "1")
+	if err = fmt.Errorf("loading token: %w", err); !errors.Is(err, ErrNotFound) {
+		t.Errorf("errors.Is(%v, ErrNotFound) = false, want true", err)
+	}
+}
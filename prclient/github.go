@@ -0,0 +1,161 @@
+/*
+GitHub implements access to prGitHub microservice which links GitHub
+organizations, through a GitHub App installation, to a Token used to access
+them.
+
+HTTP verbs are translated into the following function calls:
+
+Verbs to Functions
+------   ---------
+POST     Link
+POST     Sync (/uuid/sync)
+GET      Get
+GET/     List resources, ListOrgs
+DELETE   Unlink
+PATCH    Patch
+*/
+package prclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// GitHubService handles communication with the GitHub integration related
+// methods of the PavedRoad API.
+type GitHubService service
+
+// GitHubIntegration data structure for a linked GitHub organization
+type GitHubIntegration struct {
+	APIVersion     string           `json:"apiVersion"`
+	Kind           string           `json:"kind"`
+	Metadata       ResourceMetadata `json:"metadata"`
+	Org            string           `json:"org"`
+	InstallationID int64            `json:"installationID"`
+	TokenUID       string           `json:"tokenUID"` // UID of the Token used to access the organization
+	SyncStatus     string           `json:"syncStatus"`
+	LastSync       *Timestamp       `json:"lastSync,omitempty"`
+	Created        *Timestamp       `json:"created,omitempty"`
+	Updated        *Timestamp       `json:"updated,omitempty"`
+}
+
+func (g GitHubIntegration) String() string {
+	return Stringify(g)
+}
+
+// resource returns the generic client for prGitHub resources.
+func (s *GitHubService) resource() *ResourceClient[GitHubIntegration] {
+	return NewResourceClient(s.client, gitHubResource, "UUID", func(g *GitHubIntegration) string {
+		return g.Metadata.UID
+	})
+}
+
+// Link links the GitHub organization org, reached through the GitHub App
+// installation installationID, to the Token identified by tokenUID.
+// PavedRoad API endpoint /prGitHub/
+func (s *GitHubService) Link(ctx context.Context, org string, installationID int64, tokenUID string) (*GitHubIntegration, *Response, error) {
+	if org == "" {
+		return nil, nil, errors.New("org is required")
+	}
+	if tokenUID == "" {
+		return nil, nil, errors.New("token UID is required")
+	}
+	return s.resource().Create(ctx, &GitHubIntegration{
+		Org:            org,
+		InstallationID: installationID,
+		TokenUID:       tokenUID,
+	})
+}
+
+// Get fetches a GitHub integration based on a UUID.
+// PavedRoad API endpoint /prGitHub/uuid.
+func (s *GitHubService) Get(ctx context.Context, uuid string) (*GitHubIntegration, *Response, error) {
+	return s.resource().Get(ctx, uuid)
+}
+
+// Unlink deletes a GitHub integration using a UUID.
+// PavedRoad API endpoint /prGitHub/uuid.
+func (s *GitHubService) Unlink(ctx context.Context, uuid string) (*Response, error) {
+	return s.resource().Delete(ctx, uuid)
+}
+
+// Patch applies a MergePatch or a JSONPatch to a GitHub integration, e.g. to
+// link it to another token.
+// PavedRoad API endpoint /prGitHub/uuid.
+func (s *GitHubService) Patch(ctx context.Context, uuid string, patch PatchDocument) (*GitHubIntegration, *Response, error) {
+	return s.resource().Patch(ctx, uuid, patch)
+}
+
+// Sync asks PavedRoad to synchronize the organization of a GitHub
+// integration. The synchronization runs in the background: a 202 Accepted
+// answer means it was scheduled and is not reported as an error.
+// PavedRoad API endpoint /prGitHub/uuid/sync.
+func (s *GitHubService) Sync(ctx context.Context, uuid string) (*Response, error) {
+	if uuid == "" {
+		return nil, errors.New("UUID is required")
+	}
+	u := fmt.Sprintf("%s/%v/sync", gitHubResource, uuid)
+
+	req, err := s.client.NewRequest("POST", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(ctx, req, nil)
+	if _, ok := err.(*AcceptedError); ok {
+		return resp, nil
+	}
+	return resp, err
+}
+
+// GitHubListOptions specifies optional parameters to the GitHubService.List
+// and GitHubService.ListAll methods.
+type GitHubListOptions struct {
+	// UID of the last integration seen
+	Since string `url:"since,omitempty"`
+
+	// Note: when the server does not return Link headers, pagination is
+	// powered by the Since parameter and ListOptions.Page has no effect.
+	ListOptions
+}
+
+// Advance implements Pager.
+func (o *GitHubListOptions) Advance(resp *Response, n int, last string) bool {
+	return advancePage(&o.ListOptions, &o.Since, resp, n, last)
+}
+
+// List lists one page of GitHub integrations.
+// PavedRoad API endpoint /prGitHubLIST/
+func (s *GitHubService) List(ctx context.Context, opt *GitHubListOptions) ([]*GitHubIntegration, *Response, error) {
+	return s.resource().List(ctx, opt)
+}
+
+// ListAll walks every page of GitHub integrations starting at opt and
+// returns them all. See ResourceClient.ListAll.
+func (s *GitHubService) ListAll(ctx context.Context, opt *GitHubListOptions) ([]*GitHubIntegration, error) {
+	var o GitHubListOptions
+	if opt != nil {
+		o = *opt
+	}
+	return s.resource().ListAll(ctx, &o)
+}
+
+// ListOrgs returns the names of all linked GitHub organizations.
+// PavedRoad API endpoint /prGitHubLIST/
+func (s *GitHubService) ListOrgs(ctx context.Context) ([]string, error) {
+	integrations, err := s.ListAll(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(integrations))
+	var orgs []string
+	for _, g := range integrations {
+		if !seen[g.Org] {
+			seen[g.Org] = true
+			orgs = append(orgs, g.Org)
+		}
+	}
+	return orgs, nil
+}
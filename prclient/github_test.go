@@ -0,0 +1,97 @@
+package prclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var blankGitHubIntegrationJSON = `{"apiVersion":"1","kind":"","metadata":{"name":"","namespace":"","uid":""},"org":"","installationID":0,"tokenUID":"","syncStatus":""}`
+
+func TestGitHubIntegration_marshall(t *testing.T) {
+	g := &GitHubIntegration{APIVersion: "1"}
+	testJSONMarshal(t, g, blankGitHubIntegrationJSON)
+}
+
+func TestGitHubService_Link(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+gitHubResource+"/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		testBody(t, r, `{"apiVersion":"","kind":"","metadata":{"name":"","namespace":"","uid":""},"org":"pavedroad-io","installationID":42,"tokenUID":"t-1","syncStatus":""}`+"\n")
+		fmt.Fprint(w, `{"metadata":{"uid":"g-1"},"org":"pavedroad-io","installationID":42,"tokenUID":"t-1"}`)
+	})
+
+	g, _, err := client.GitHub.Link(context.Background(), "pavedroad-io", 42, "t-1")
+	if err != nil {
+		t.Errorf("GitHub.Link returned error: %v", err)
+	}
+
+	want := &GitHubIntegration{Metadata: ResourceMetadata{UID: "g-1"}, Org: "pavedroad-io", InstallationID: 42, TokenUID: "t-1"}
+	if !cmp.Equal(g, want) {
+		t.Errorf("GitHub.Link returned %+v, want %+v", g, want)
+	}
+}
+
+func TestGitHubService_Link_invalid(t *testing.T) {
+	client := NewClient(nil)
+	if _, _, err := client.GitHub.Link(context.Background(), "", 1, "t-1"); err == nil {
+		t.Error("Expected error to be returned for an empty org.")
+	}
+	if _, _, err := client.GitHub.Link(context.Background(), "o", 1, ""); err == nil {
+		t.Error("Expected error to be returned for an empty token UID.")
+	}
+}
+
+func TestGitHubService_Unlink(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+gitHubResource+"/g-1", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "DELETE")
+	})
+
+	if _, err := client.GitHub.Unlink(context.Background(), "g-1"); err != nil {
+		t.Errorf("GitHub.Unlink returned error: %v", err)
+	}
+}
+
+func TestGitHubService_Sync(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+gitHubResource+"/g-1/sync", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	resp, err := client.GitHub.Sync(context.Background(), "g-1")
+	if err != nil {
+		t.Errorf("GitHub.Sync returned error: %v", err)
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("GitHub.Sync returned status %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+}
+
+func TestGitHubService_ListOrgs(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+gitHubResource+"LIST/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, `[{"org":"a"},{"org":"b"},{"org":"a"}]`)
+	})
+
+	orgs, err := client.GitHub.ListOrgs(context.Background())
+	if err != nil {
+		t.Errorf("GitHub.ListOrgs returned error: %v", err)
+	}
+	if want := []string{"a", "b"}; !cmp.Equal(orgs, want) {
+		t.Errorf("GitHub.ListOrgs returned %v, want %v", orgs, want)
+	}
+}
@@ -0,0 +1,450 @@
+package prclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// IndexFunc returns the values under which an object is indexed.
+type IndexFunc[T any] func(obj *T) []string
+
+// Store is a concurrency-safe in-memory cache of objects of type T, keyed
+// by keyOf and optionally indexed. The objects it returns are shared and
+// must not be modified.
+type Store[T any] struct {
+	keyOf    func(*T) string
+	indexers map[string]IndexFunc[T]
+
+	mu      sync.RWMutex
+	items   map[string]*T
+	indices map[string]map[string]map[string]bool // index name -> value -> keys
+}
+
+// NewStore returns an empty store whose objects are keyed by keyOf and
+// indexed by indexers, keyed by index name.
+func NewStore[T any](keyOf func(*T) string, indexers map[string]IndexFunc[T]) *Store[T] {
+	s := &Store[T]{
+		keyOf:    keyOf,
+		indexers: indexers,
+		items:    map[string]*T{},
+		indices:  map[string]map[string]map[string]bool{},
+	}
+	for name := range indexers {
+		s.indices[name] = map[string]map[string]bool{}
+	}
+	return s
+}
+
+// Get returns the object of key.
+func (s *Store[T]) Get(key string) (*T, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	obj, ok := s.items[key]
+	return obj, ok
+}
+
+// List returns every object, sorted by key.
+func (s *Store[T]) List() []*T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	objs := make([]*T, 0, len(s.items))
+	for _, k := range sortedKeys(s.items) {
+		objs = append(objs, s.items[k])
+	}
+	return objs
+}
+
+// Len returns the number of objects.
+func (s *Store[T]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.items)
+}
+
+// ByIndex returns the objects indexed under value by the index name, sorted
+// by key. It returns nil for an unknown index.
+func (s *Store[T]) ByIndex(name, value string) []*T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := s.indices[name][value]
+	objs := make([]*T, 0, len(keys))
+	for _, k := range sortedKeys(keys) {
+		objs = append(objs, s.items[k])
+	}
+	return objs
+}
+
+// set stores obj and returns the object it replaced, if any.
+func (s *Store[T]) set(obj *T) (old *T, ok bool) {
+	key := s.keyOf(obj)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok = s.items[key]
+	if ok {
+		s.unindex(key, old)
+	}
+	s.items[key] = obj
+	for name, f := range s.indexers {
+		for _, v := range f(obj) {
+			keys := s.indices[name][v]
+			if keys == nil {
+				keys = map[string]bool{}
+				s.indices[name][v] = keys
+			}
+			keys[key] = true
+		}
+	}
+	return old, ok
+}
+
+// remove deletes the object of key and returns it, if any.
+func (s *Store[T]) remove(key string) (old *T, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, ok = s.items[key]
+	if ok {
+		s.unindex(key, old)
+		delete(s.items, key)
+	}
+	return old, ok
+}
+
+func (s *Store[T]) unindex(key string, obj *T) {
+	for name, f := range s.indexers {
+		for _, v := range f(obj) {
+			delete(s.indices[name][v], key)
+			if len(s.indices[name][v]) == 0 {
+				delete(s.indices[name], v)
+			}
+		}
+	}
+}
+
+// EventHandlers are called by an Informer as its store changes. Any of
+// them may be nil.
+type EventHandlers[T any] struct {
+	OnAdd    func(obj *T)
+	OnUpdate func(old, new *T)
+	OnDelete func(obj *T)
+}
+
+// InformerOptions specifies optional parameters to the informers.
+type InformerOptions struct {
+	// ResyncPeriod is the delay between two lists of the objects when
+	// the server cannot watch them, or after a watch failed. Defaults to
+	// one minute.
+	ResyncPeriod time.Duration
+
+	// DisableWatch keeps the store up to date with periodic lists only.
+	DisableWatch bool
+
+	// OnError, if set, is called with the errors that do not stop the
+	// informer, e.g. a failed resync.
+	OnError func(error)
+}
+
+const defaultResyncPeriod = time.Minute
+
+// Informer keeps a Store of the objects of a resource up to date and calls
+// event handlers as they change. It lists the objects, then follows the
+// changes with a watch if the server supports it, listing them again once
+// the watch is established so that no change made in between is missed, or
+// lists them every resync period otherwise.
+type Informer[T any] struct {
+	store *Store[T]
+	list  func(ctx context.Context) ([]*T, error)
+	watch func(ctx context.Context, opt *WatchOptions) (<-chan WatchEvent[T], error)
+	opt   InformerOptions
+
+	mu       sync.Mutex
+	handlers []EventHandlers[T]
+	synced   chan struct{}
+}
+
+func newInformer[T any](store *Store[T], list func(context.Context) ([]*T, error),
+	watch func(context.Context, *WatchOptions) (<-chan WatchEvent[T], error), opt *InformerOptions) *Informer[T] {
+	i := &Informer[T]{store: store, list: list, watch: watch, synced: make(chan struct{})}
+	if opt != nil {
+		i.opt = *opt
+	}
+	if i.opt.ResyncPeriod <= 0 {
+		i.opt.ResyncPeriod = defaultResyncPeriod
+	}
+	return i
+}
+
+// Store returns the store of i.
+func (i *Informer[T]) Store() *Store[T] {
+	return i.store
+}
+
+// List returns every object in the store of i, sorted by key.
+func (i *Informer[T]) List() []*T {
+	return i.store.List()
+}
+
+// AddEventHandler registers h. Handlers are called in order, one event at a
+// time, from the goroutine calling Run; they should return quickly. OnUpdate
+// is only called when an object changed. Handlers added after the initial
+// list receive no OnAdd for the objects already in the store.
+func (i *Informer[T]) AddEventHandler(h EventHandlers[T]) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.handlers = append(i.handlers, h)
+}
+
+// HasSynced reports whether the initial list is in the store.
+func (i *Informer[T]) HasSynced() bool {
+	select {
+	case <-i.synced:
+		return true
+	default:
+		return false
+	}
+}
+
+// WaitForSync waits until the initial list is in the store or ctx is done.
+func (i *Informer[T]) WaitForSync(ctx context.Context) error {
+	select {
+	case <-i.synced:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Run fills the store and keeps it up to date until ctx is done. It returns
+// the error of the initial list, or nil once ctx is done. Run must be
+// called only once.
+func (i *Informer[T]) Run(ctx context.Context) error {
+	if err := i.resync(ctx); err != nil {
+		return err
+	}
+	close(i.synced)
+
+	watching := i.watch != nil && !i.opt.DisableWatch
+	for {
+		if watching {
+			err := i.runWatch(ctx)
+			switch {
+			case ctx.Err() != nil:
+				return nil
+			case watchUnsupported(err):
+				watching = false
+			case errors.Is(err, ErrWatchExpired):
+				// Events were missed: watch again right away, which
+				// lists the objects once the watch is established.
+				continue
+			}
+		}
+
+		t := time.NewTimer(i.opt.ResyncPeriod)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return nil
+		case <-t.C:
+		}
+		if err := i.resync(ctx); err != nil && ctx.Err() == nil {
+			i.error(err)
+		}
+	}
+}
+
+// resync lists the objects and brings the store in line with them.
+func (i *Informer[T]) resync(ctx context.Context) error {
+	objs, err := i.list(ctx)
+	if err != nil {
+		return err
+	}
+
+	listed := map[string]bool{}
+	for _, obj := range objs {
+		listed[i.store.keyOf(obj)] = true
+		i.update(obj)
+	}
+	for _, obj := range i.store.List() {
+		if key := i.store.keyOf(obj); !listed[key] {
+			i.remove(key)
+		}
+	}
+	return nil
+}
+
+// runWatch applies the events of a watch to the store until it ends, and
+// returns the error that ended it. The objects are listed again once the
+// watch is established, as the changes made since the last list are not
+// part of it.
+func (i *Informer[T]) runWatch(ctx context.Context) error {
+	connected := make(chan struct{})
+	events, err := i.watch(ctx, &WatchOptions{established: func() { close(connected) }})
+	if err != nil {
+		i.error(err)
+		return err
+	}
+
+	// resync lists the objects once the watch is established, before
+	// applying its first event.
+	established := (<-chan struct{})(connected)
+	resync := func() {
+		established = nil
+		if err := i.resync(ctx); err != nil && ctx.Err() == nil {
+			i.error(err)
+		}
+	}
+
+	var last error
+	for {
+		select {
+		case <-established:
+			resync()
+		case e, ok := <-events:
+			if !ok {
+				return last
+			}
+			select {
+			case <-established:
+				resync()
+			default:
+			}
+			switch e.Type {
+			case EventAdded, EventModified:
+				i.update(e.Object)
+			case EventDeleted:
+				i.remove(i.store.keyOf(e.Object))
+			case EventError:
+				last = e.Err
+				i.error(e.Err)
+				// The object changed but cannot be decoded, e.g.
+				// decrypted: drop its stale state.
+				if e.Object != nil {
+					i.remove(i.store.keyOf(e.Object))
+				}
+			}
+		}
+	}
+}
+
+// update stores obj and calls the handlers if it is new or changed.
+func (i *Informer[T]) update(obj *T) {
+	old, ok := i.store.set(obj)
+	switch {
+	case !ok:
+		for _, h := range i.eventHandlers() {
+			if h.OnAdd != nil {
+				h.OnAdd(obj)
+			}
+		}
+	case !reflect.DeepEqual(old, obj):
+		for _, h := range i.eventHandlers() {
+			if h.OnUpdate != nil {
+				h.OnUpdate(old, obj)
+			}
+		}
+	}
+}
+
+// remove deletes the object of key and, if it was in the store, calls the
+// handlers with its last known state.
+func (i *Informer[T]) remove(key string) {
+	old, ok := i.store.remove(key)
+	if !ok {
+		return
+	}
+	for _, h := range i.eventHandlers() {
+		if h.OnDelete != nil {
+			h.OnDelete(old)
+		}
+	}
+}
+
+func (i *Informer[T]) eventHandlers() []EventHandlers[T] {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.handlers
+}
+
+func (i *Informer[T]) error(err error) {
+	if i.opt.OnError != nil {
+		i.opt.OnError(err)
+	}
+}
+
+// watchUnsupported reports whether a watch failed because the server does
+// not implement it.
+func watchUnsupported(err error) bool {
+	var er *ErrorResponse
+	if !errors.As(err, &er) || er.Response == nil {
+		return false
+	}
+	switch er.Response.StatusCode {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	}
+	return false
+}
+
+// Names of the indexes of the informers.
+const (
+	TokenNameIndex  = "name"
+	MapperUserIndex = "userUUID"
+)
+
+// TokenInformer caches the PavedRoad tokens of a namespace, with their
+// secrets decrypted.
+type TokenInformer struct {
+	*Informer[Token]
+}
+
+// NewTokenInformer returns an informer for the tokens of the namespace of
+// c; call its Run method to start it. Tokens are keyed by UID and indexed by
+// name under TokenNameIndex.
+func NewTokenInformer(c *Client, opt *InformerOptions) *TokenInformer {
+	store := NewStore(func(t *Token) string { return t.Metadata.UID }, map[string]IndexFunc[Token]{
+		TokenNameIndex: func(t *Token) []string { return []string{t.Metadata.Name} },
+	})
+	list := func(ctx context.Context) ([]*Token, error) { return c.Token.ListAll(ctx, nil) }
+	return &TokenInformer{newInformer(store, list, c.Token.Watch, opt)}
+}
+
+// Get returns the token of uid.
+func (i *TokenInformer) Get(uid string) (*Token, bool) {
+	return i.store.Get(uid)
+}
+
+// ByName returns the tokens named name.
+func (i *TokenInformer) ByName(name string) []*Token {
+	return i.store.ByIndex(TokenNameIndex, name)
+}
+
+// UserIdMapperInformer caches the PavedRoad user ID mappers of a namespace,
+// e.g. to resolve credentials at login without a request.
+type UserIdMapperInformer struct {
+	*Informer[UserIdMapper]
+}
+
+// NewUserIdMapperInformer returns an informer for the user ID mappers of
+// the namespace of c; call its Run method to start it. Mappers are keyed by
+// credential and indexed by user UUID under MapperUserIndex.
+func NewUserIdMapperInformer(c *Client, opt *InformerOptions) *UserIdMapperInformer {
+	store := NewStore(func(m *UserIdMapper) string { return m.Credential }, map[string]IndexFunc[UserIdMapper]{
+		MapperUserIndex: func(m *UserIdMapper) []string { return []string{m.UserUUID} },
+	})
+	list := func(ctx context.Context) ([]*UserIdMapper, error) { return c.UserIdMapper.ListAll(ctx, nil) }
+	return &UserIdMapperInformer{newInformer(store, list, c.UserIdMapper.Watch, opt)}
+}
+
+// GetByCredential returns the mapper of cred, like UserIdMappersService.Get
+// but from the cache.
+func (i *UserIdMapperInformer) GetByCredential(cred string) (*UserIdMapper, bool) {
+	return i.store.Get(cred)
+}
+
+// ByUser returns the mappers of the user userUUID.
+func (i *UserIdMapperInformer) ByUser(userUUID string) []*UserIdMapper {
+	return i.store.ByIndex(MapperUserIndex, userUUID)
+}
@@ -0,0 +1,294 @@
+package prclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestStore(t *testing.T) {
+	s := NewStore(func(m *UserIdMapper) string { return m.Credential }, map[string]IndexFunc[UserIdMapper]{
+		MapperUserIndex: func(m *UserIdMapper) []string { return []string{m.UserUUID} },
+	})
+	s.set(&UserIdMapper{Credential: "b", UserUUID: "u1"})
+	s.set(&UserIdMapper{Credential: "a", UserUUID: "u1"})
+	s.set(&UserIdMapper{Credential: "c", UserUUID: "u2"})
+
+	credentials := func(ms []*UserIdMapper) []string {
+		var creds []string
+		for _, m := range ms {
+			creds = append(creds, m.Credential)
+		}
+		return creds
+	}
+	if got, want := credentials(s.ByIndex(MapperUserIndex, "u1")), []string{"a", "b"}; !cmp.Equal(got, want) {
+		t.Errorf("ByIndex returned %q, want %q", got, want)
+	}
+
+	// Moving b to another user updates the index.
+	if old, ok := s.set(&UserIdMapper{Credential: "b", UserUUID: "u2"}); !ok || old.UserUUID != "u1" {
+		t.Errorf("set returned %+v, %v, want the previous mapper", old, ok)
+	}
+	s.remove("c")
+	if got, want := credentials(s.ByIndex(MapperUserIndex, "u2")), []string{"b"}; !cmp.Equal(got, want) {
+		t.Errorf("ByIndex returned %q, want %q", got, want)
+	}
+	if got, want := credentials(s.List()), []string{"a", "b"}; !cmp.Equal(got, want) || s.Len() != 2 {
+		t.Errorf("List returned %q, want %q", got, want)
+	}
+	if _, ok := s.Get("c"); ok {
+		t.Error("Get returned a removed mapper")
+	}
+	if got := s.ByIndex("bogus", "u1"); len(got) != 0 {
+		t.Errorf("ByIndex of an unknown index returned %v", got)
+	}
+}
+
+// recorder records the events of an informer.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) handlers() EventHandlers[UserIdMapper] {
+	return EventHandlers[UserIdMapper]{
+		OnAdd:    func(m *UserIdMapper) { r.add("add %s %d", m.Credential, m.LoginCount) },
+		OnUpdate: func(old, m *UserIdMapper) { r.add("update %s %d->%d", m.Credential, old.LoginCount, m.LoginCount) },
+		OnDelete: func(m *UserIdMapper) { r.add("delete %s", m.Credential) },
+	}
+}
+
+func (r *recorder) add(format string, a ...interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, fmt.Sprintf(format, a...))
+}
+
+// wait waits for n events and returns them.
+func (r *recorder) wait(t *testing.T, n int) []string {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		r.mu.Lock()
+		events := append([]string(nil), r.events...)
+		r.mu.Unlock()
+		if len(events) >= n {
+			return events
+		}
+	}
+	t.Fatalf("timed out waiting for %d events, got %q", n, r.events)
+	return nil
+}
+
+func TestUserIdMapperInformer_resync(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	var mu sync.Mutex
+	lists := []string{
+		`[{"login": "a", "loginCount": 1}, {"login": "b", "loginCount": 1}]`,
+		`[{"login": "a", "loginCount": 2}, {"login": "c", "loginCount": 1}]`,
+	}
+	mux.HandleFunc("/"+mapperResource+"LIST/", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("watch") == "true" {
+			http.Error(w, "Not Found", http.StatusNotFound)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		fmt.Fprint(w, lists[0])
+		if len(lists) > 1 {
+			lists = lists[1:]
+		}
+	})
+
+	inf := NewUserIdMapperInformer(client, &InformerOptions{ResyncPeriod: 10 * time.Millisecond})
+	var rec recorder
+	inf.AddEventHandler(rec.handlers())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- inf.Run(ctx) }()
+
+	if err := inf.WaitForSync(ctx); err != nil {
+		t.Fatalf("WaitForSync returned error: %v", err)
+	}
+	if m, ok := inf.GetByCredential("b"); !ok || m.LoginCount != 1 {
+		t.Errorf("GetByCredential returned %+v, %v after the initial list", m, ok)
+	}
+
+	want := []string{"add a 1", "add b 1", "update a 1->2", "add c 1", "delete b"}
+	if got := rec.wait(t, len(want)); !cmp.Equal(got, want) {
+		t.Errorf("UserIdMapperInformer handled %q, want %q", got, want)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Run returned error: %v", err)
+	}
+}
+
+func TestUserIdMapperInformer_watch(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	events := make(chan string)
+	mux.HandleFunc("/"+mapperResource+"LIST/", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("watch") != "true" {
+			fmt.Fprint(w, `[{"login": "a", "userUUID": "u1", "loginCount": 1}]`)
+			return
+		}
+		w.(http.Flusher).Flush()
+		for {
+			select {
+			case e := <-events:
+				fmt.Fprintln(w, e)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	})
+
+	inf := NewUserIdMapperInformer(client, nil)
+	var rec recorder
+	inf.AddEventHandler(rec.handlers())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go inf.Run(ctx)
+
+	send := func(typ string, m UserIdMapper) {
+		b, _ := json.Marshal(m)
+		events <- fmt.Sprintf(`{"type": %q, "object": %s}`, typ, b)
+	}
+	send("MODIFIED", UserIdMapper{Credential: "a", UserUUID: "u1", LoginCount: 2})
+	send("ADDED", UserIdMapper{Credential: "b", UserUUID: "u1", LoginCount: 1})
+	// Deleting an unknown mapper calls no handler.
+	send("DELETED", UserIdMapper{Credential: "z", UserUUID: "u1"})
+	send("DELETED", UserIdMapper{Credential: "a", UserUUID: "u1", LoginCount: 2})
+
+	want := []string{"add a 1", "update a 1->2", "add b 1", "delete a"}
+	if got := rec.wait(t, len(want)); !cmp.Equal(got, want) {
+		t.Errorf("UserIdMapperInformer handled %q, want %q", got, want)
+	}
+	if ms := inf.ByUser("u1"); len(ms) != 1 || ms[0].Credential != "b" {
+		t.Errorf("ByUser returned %v, want mapper b", ms)
+	}
+}
+
+func TestUserIdMapperInformer_watchAfterChange(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	// The mapper changes between the initial list and the watch, which
+	// then sends no event.
+	var mu sync.Mutex
+	list := `[{"login": "a", "loginCount": 1}]`
+	mux.HandleFunc("/"+mapperResource+"LIST/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if r.FormValue("watch") != "true" {
+			fmt.Fprint(w, list)
+			mu.Unlock()
+			return
+		}
+		list = `[{"login": "a", "loginCount": 2}]`
+		mu.Unlock()
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	inf := NewUserIdMapperInformer(client, nil)
+	var rec recorder
+	inf.AddEventHandler(rec.handlers())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go inf.Run(ctx)
+
+	want := []string{"add a 1", "update a 1->2"}
+	if got := rec.wait(t, len(want)); !cmp.Equal(got, want) {
+		t.Errorf("UserIdMapperInformer handled %q, want %q", got, want)
+	}
+}
+
+func TestTokenInformer_watchDecryptError(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+	kp := testKeyProvider(t)
+	client.KeyProvider = kp
+
+	secret, err := encryptSecret(context.Background(), kp, "s3cr3t", secretAAD("1"))
+	if err != nil {
+		t.Fatalf("encryptSecret returned error: %v", err)
+	}
+	other, err := encryptSecret(context.Background(), kp, "s3cr3t", secretAAD("2"))
+	if err != nil {
+		t.Fatalf("encryptSecret returned error: %v", err)
+	}
+	mux.HandleFunc("/"+tokenResource+"LIST/", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("watch") != "true" {
+			fmt.Fprintf(w, `[{"metadata": {"uid": "1", "name": "ci", "token": %q}}]`, secret)
+			return
+		}
+		// The secret of the token is now encrypted for another token.
+		fmt.Fprintf(w, `{"type": "MODIFIED", "object": {"metadata": {"uid": "1", "name": "ci", "token": %q}}}`+"\n", other)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	var errs, deleted []string
+	var mu sync.Mutex
+	inf := NewTokenInformer(client, &InformerOptions{OnError: func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err.Error())
+	}})
+	inf.AddEventHandler(EventHandlers[Token]{OnDelete: func(t *Token) {
+		mu.Lock()
+		defer mu.Unlock()
+		deleted = append(deleted, t.Metadata.UID)
+	}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go inf.Run(ctx)
+
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		mu.Lock()
+		n := len(deleted)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !cmp.Equal(deleted, []string{"1"}) || len(errs) == 0 {
+		t.Errorf("TokenInformer deleted %q with errors %q, want token 1 deleted after an error", deleted, errs)
+	}
+	if _, ok := inf.Get("1"); ok {
+		t.Error("TokenInformer kept the token that failed to decrypt")
+	}
+}
+
+func TestTokenInformer_listError(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+tokenResource+"LIST/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	})
+
+	inf := NewTokenInformer(client, nil)
+	if err := inf.Run(context.Background()); err == nil {
+		t.Error("Expected error to be returned.")
+	}
+	if inf.HasSynced() {
+		t.Error("HasSynced returned true after a failed list")
+	}
+}
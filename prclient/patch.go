@@ -0,0 +1,116 @@
+package prclient
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+const (
+	mediaTypeMergePatch = "application/merge-patch+json"
+	mediaTypeJSONPatch  = "application/json-patch+json"
+)
+
+// PatchDocument is a partial update sent by the Patch methods of the
+// services. It is JSON encoded as the request body and sent with its
+// ContentType.
+type PatchDocument interface {
+	ContentType() string
+}
+
+// MergePatch is a JSON merge patch as defined by RFC 7386. Only the members
+// present are changed on the server; a nil value removes the member.
+//
+//	prclient.MergePatch{"active": false}
+type MergePatch map[string]interface{}
+
+// ContentType returns the media type of JSON merge patches.
+func (MergePatch) ContentType() string { return mediaTypeMergePatch }
+
+// JSONPatch is a list of JSON patch operations as defined by RFC 6902.
+type JSONPatch []PatchOperation
+
+// ContentType returns the media type of JSON patches.
+func (JSONPatch) ContentType() string { return mediaTypeJSONPatch }
+
+// PatchOperation is a single RFC 6902 operation, e.g.
+//
+//	prclient.PatchOperation{Op: "replace", Path: "/metadata/scope/0", Value: "repo"}
+type PatchOperation struct {
+	Op    string      `json:"op"`             // add, remove, replace, move, copy or test
+	Path  string      `json:"path"`           // JSON pointer to the target member
+	From  string      `json:"from,omitempty"` // source of move and copy operations
+	Value interface{} `json:"value"`          // value of add, replace and test operations
+}
+
+// MarshalJSON encodes op with a value, even a null one, only for the
+// operations that take one.
+func (op PatchOperation) MarshalJSON() ([]byte, error) {
+	type operation struct {
+		Op   string `json:"op"`
+		Path string `json:"path"`
+		From string `json:"from,omitempty"`
+	}
+	o := operation{Op: op.Op, Path: op.Path, From: op.From}
+	switch op.Op {
+	case "add", "replace", "test":
+		return json.Marshal(struct {
+			operation
+			Value interface{} `json:"value"`
+		}{o, op.Value})
+	}
+	return json.Marshal(o)
+}
+
+// CreateMergePatch returns the JSON merge patch that turns original into
+// modified, both being values of the same type such as *Token. Members that
+// did not change are left out of the patch, so applying it cannot clobber
+// fields updated by someone else.
+func CreateMergePatch(original, modified interface{}) (MergePatch, error) {
+	o, err := toJSONObject(original)
+	if err != nil {
+		return nil, err
+	}
+	m, err := toJSONObject(modified)
+	if err != nil {
+		return nil, err
+	}
+	return mergeDiff(o, m), nil
+}
+
+// toJSONObject converts v into its generic JSON object representation.
+func toJSONObject(v interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	obj := map[string]interface{}{}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+// mergeDiff returns the merge patch turning the JSON object original into
+// modified.
+func mergeDiff(original, modified map[string]interface{}) MergePatch {
+	patch := MergePatch{}
+	for k := range original {
+		if _, ok := modified[k]; !ok {
+			patch[k] = nil
+		}
+	}
+	for k, mv := range modified {
+		ov, ok := original[k]
+		om, oIsObj := ov.(map[string]interface{})
+		mm, mIsObj := mv.(map[string]interface{})
+		switch {
+		case ok && oIsObj && mIsObj:
+			if sub := mergeDiff(om, mm); len(sub) > 0 {
+				patch[k] = map[string]interface{}(sub)
+			}
+		case !ok || !reflect.DeepEqual(ov, mv):
+			patch[k] = mv
+		}
+	}
+	return patch
+}
@@ -0,0 +1,98 @@
+package prclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCreateMergePatch(t *testing.T) {
+	original := NewToken()
+	modified := NewToken()
+	modified.Active = false
+	modified.Metadata.Scope = nil
+	modified.Metadata.Site = "gitlab"
+
+	patch, err := CreateMergePatch(original, modified)
+	if err != nil {
+		t.Fatalf("CreateMergePatch returned error: %v", err)
+	}
+
+	want := MergePatch{
+		"active": false,
+		"metadata": map[string]interface{}{
+			"scope": nil,
+			"site":  "gitlab",
+		},
+	}
+	if !cmp.Equal(patch, want) {
+		t.Errorf("CreateMergePatch returned %v, want %v", patch, want)
+	}
+}
+
+func TestCreateMergePatch_unchanged(t *testing.T) {
+	patch, err := CreateMergePatch(NewToken(), NewToken())
+	if err != nil {
+		t.Fatalf("CreateMergePatch returned error: %v", err)
+	}
+	if len(patch) != 0 {
+		t.Errorf("CreateMergePatch returned %v, want an empty patch", patch)
+	}
+}
+
+func TestTokensService_Patch(t *testing.T) {
+	tests := []struct {
+		patch       PatchDocument
+		contentType string
+		body        string
+	}{
+		{MergePatch{"active": false}, mediaTypeMergePatch, `{"active":false}`},
+		{JSONPatch{{Op: "remove", Path: "/metadata/scope/0"}}, mediaTypeJSONPatch, `[{"op":"remove","path":"/metadata/scope/0"}]`},
+		{JSONPatch{{Op: "replace", Path: "/metadata/site", Value: nil}, {Op: "move", From: "/a", Path: "/b"}}, mediaTypeJSONPatch,
+			`[{"op":"replace","path":"/metadata/site","value":null},{"op":"move","path":"/b","from":"/a"}]`},
+	}
+	for _, tt := range tests {
+		client, mux, _, teardown := setup()
+
+		mux.HandleFunc("/"+tokenResource+"/1", func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "PATCH")
+			testHeader(t, r, "Content-Type", tt.contentType)
+			testBody(t, r, tt.body+"\n")
+			fmt.Fprint(w, blankTokenJSON)
+		})
+
+		token, _, err := client.Token.Patch(context.Background(), "1", tt.patch)
+		if err != nil {
+			t.Errorf("Tokens.Patch returned error: %v", err)
+		}
+		if want := (&Token{APIVersion: "1"}); !cmp.Equal(token, want) {
+			t.Errorf("Tokens.Patch returned %+v, want %+v", token, want)
+		}
+		teardown()
+	}
+}
+
+func TestResourceClient_Patch_nil(t *testing.T) {
+	if _, _, err := NewClient(nil).Token.Patch(context.Background(), "1", nil); err == nil {
+		t.Error("Expected error to be returned.")
+	}
+}
+
+func TestUserIdMappersService_Patch(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+mapperResource+"/foo", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "PATCH")
+		testHeader(t, r, "Content-Type", mediaTypeMergePatch)
+		testBody(t, r, `{"loginCount":2}`+"\n")
+		fmt.Fprint(w, blankUserIdMapperJSON)
+	})
+
+	if _, _, err := client.UserIdMapper.Patch(context.Background(), "foo", MergePatch{"loginCount": 2}); err != nil {
+		t.Errorf("UserIdMappers.Patch returned error: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package prclient
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// PollOptions specifies how Client.DoUntilReady repeats a request while
+// PavedRoad answers 202 Accepted.
+type PollOptions struct {
+	// Interval is the delay before the first repeat. Defaults to one second.
+	Interval time.Duration
+
+	// Multiplier grows the delay after every repeat. Values below 1 keep
+	// the delay constant.
+	Multiplier float64
+
+	// MaxInterval caps the delay between two attempts. Zero means no cap.
+	MaxInterval time.Duration
+
+	// Timeout is the overall deadline for the request, including all
+	// repeats. Zero means the request is repeated until ctx is done.
+	Timeout time.Duration
+}
+
+const defaultPollInterval = time.Second
+
+// DoUntilReady sends an API request like Do, but while PavedRoad answers
+// with 202 Accepted (an *AcceptedError) it waits and sends the request again,
+// until the final response is decoded into v.
+//
+// If the deadline given by opt.Timeout or ctx is hit while results are still
+// not ready, the last *AcceptedError is returned so its Raw payload is not
+// lost. A Retry-After header on a 202 response overrides the poll interval.
+func (c *Client) DoUntilReady(ctx context.Context, req *http.Request, v interface{}, opt *PollOptions) (*Response, error) {
+	if opt == nil {
+		opt = &PollOptions{}
+	}
+	if opt.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opt.Timeout)
+		defer cancel()
+	}
+
+	interval := opt.Interval
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	var last *AcceptedError
+	for {
+		resp, err := c.Do(ctx, req, v)
+		aerr, ok := err.(*AcceptedError)
+		if !ok {
+			if err != nil && last != nil && ctx.Err() != nil {
+				return resp, last
+			}
+			return resp, err
+		}
+		last = aerr
+
+		if err := rewindBody(req); err != nil {
+			return resp, err
+		}
+
+		wait := interval
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			wait = d
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return resp, last
+		case <-t.C:
+		}
+
+		if opt.Multiplier > 1 {
+			interval = time.Duration(float64(interval) * opt.Multiplier)
+		}
+		if opt.MaxInterval > 0 && interval > opt.MaxInterval {
+			interval = opt.MaxInterval
+		}
+	}
+}
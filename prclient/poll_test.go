@@ -0,0 +1,76 @@
+package prclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDoUntilReady(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	attempts := 0
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		fmt.Fprint(w, `{"A":"a"}`)
+	})
+
+	req, _ := client.NewRequest("GET", ".", nil)
+	body := new(struct{ A string })
+	opt := &PollOptions{Interval: time.Millisecond, Multiplier: 2, MaxInterval: 3 * time.Millisecond}
+	if _, err := client.DoUntilReady(context.Background(), req, body, opt); err != nil {
+		t.Fatalf("DoUntilReady returned error: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("server saw %d attempts, want 3", attempts)
+	}
+	if body.A != "a" {
+		t.Errorf("Response body = %v, want a", body.A)
+	}
+}
+
+func TestDoUntilReady_timeout(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, `{"state":"pending"}`)
+	})
+
+	req, _ := client.NewRequest("GET", ".", nil)
+	opt := &PollOptions{Interval: time.Millisecond, Timeout: 20 * time.Millisecond}
+	_, err := client.DoUntilReady(context.Background(), req, nil, opt)
+
+	aerr, ok := err.(*AcceptedError)
+	if !ok {
+		t.Fatalf("DoUntilReady returned error %#v, want *AcceptedError", err)
+	}
+	if got, want := string(aerr.Raw), `{"state":"pending"}`; got != want {
+		t.Errorf("AcceptedError.Raw = %s, want %s", got, want)
+	}
+}
+
+func TestDoUntilReady_error(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+	})
+
+	req, _ := client.NewRequest("GET", ".", nil)
+	if _, err := client.DoUntilReady(context.Background(), req, nil, nil); err == nil {
+		t.Error("Expected error to be returned.")
+	} else if _, ok := err.(*ErrorResponse); !ok {
+		t.Errorf("DoUntilReady returned error %#v, want *ErrorResponse", err)
+	}
+}
@@ -1,6 +1,6 @@
 // PavedRoad APIs follow kubernetes conventions
 // /api/v1/namespace/{namespace}/resourcetype default
-// The default namespace is pavedroad.io
+// The default namespace is pavedroad.io, use Client.Namespace for others
 //
 // Verbs
 // ---------
@@ -12,7 +12,10 @@
 //
 // Resources
 // ---------
-// prToken:  A token for accessing 3rd party services
+// prToken:      A token for accessing 3rd party services
+// prUser:       A PavedRoad user account
+// prRepository: A source repository registered with PavedRoad
+// prGitHub:     A GitHub organization linked to PavedRoad
 //
 // UUID are used to identify all resources
 //
@@ -23,6 +26,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/go-querystring/query"
 	"io"
@@ -33,29 +37,33 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 const (
 	headerOTP = "X-PavedRoad-OTP"
 
+	headerRateLimit     = "X-RateLimit-Limit"
+	headerRateRemaining = "X-RateLimit-Remaining"
+	headerRateReset     = "X-RateLimit-Reset"
+
 	mediaTypeV3      = "application/vnd.pavedroad.v3+json"
 	defaultMediaType = "application/octet-stream"
 
 	apiVersion         string = "/api/v1/"
 	namespaceID        string = "namespace/"
-	defaultNamespace   string = "pavedroad.io/"
+	defaultNamespace   string = "pavedroad.io"
 	tokenResource      string = "prTokens"
 	tokenResourceList  string = "prTokensLIST"
 	gitHubResource     string = "prGitHub"
 	userResource       string = "prUser"
 	repositoryResource string = "prRepository"
-	mapperResource      string = "prUserIdMappers"
-	mapperResourceList  string = "prUserIdMappersLIST"
+	mapperResource     string = "prUserIdMappers"
 	uid                string = "{uid}"
 	cred               string = "{cred}"
 
-	// TODO: Turn this into a function
-	defaultBaseURL = "https://api.pavedroad.io" + apiVersion + namespaceID + defaultNamespace
+	// Use Client.Namespace to target another namespace
+	defaultBaseURL = "https://api.pavedroad.io" + apiVersion + namespaceID + defaultNamespace + "/"
 	uploadBaseURL  = "https://uploads.pavedroad.io/"
 	userAgent      = "prclient"
 )
@@ -76,11 +84,35 @@ type Client struct {
 	// User agent used when communicating with the PavedRoad API.
 	UserAgent string
 
+	// RetryPolicy controls how Do retries requests that fail with a
+	// transient error. Requests are attempted once if it is nil.
+	RetryPolicy *RetryPolicy
+
+	// EnforceRateLimit makes Do refuse to send requests, returning a
+	// *RateLimitError instead, while the rate limit reported by the last
+	// response is exhausted and has not been reset yet.
+	EnforceRateLimit bool
+
+	// KeyProvider, if set, makes TokensService encrypt Token.Metadata.Token
+	// before it is sent and decrypt it when it is read back, so the secret
+	// is never visible to the PavedRoad backend. See KeyProvider.
+	KeyProvider KeyProvider
+
+	rate *rateState // last known rate limit, shared by namespace views
+
+	// namespaceErr is returned by NewRequest when the client is a view of
+	// an invalid namespace.
+	namespaceErr error
+
 	common service // Reuse a single struct instead of allocating one for each service on the heap.
 
 	// Services used for talking to different parts of the PavedRoad API.
-	Token *TokensService
+	Token        *TokensService
 	UserIdMapper *UserIdMappersService
+	Users        *UsersService
+	Repositories *RepositoriesService
+	GitHub       *GitHubService
+	Uploads      *UploadsService
 }
 
 type service struct {
@@ -102,6 +134,20 @@ type UploadOptions struct {
 	Name      string `url:"name,omitempty"`
 	Label     string `url:"label,omitempty"`
 	MediaType string `url:"-"`
+
+	// Progress, if set, is called as the upload proceeds with the number of
+	// bytes sent so far and the total size.
+	Progress func(sent, total int64) `url:"-"`
+
+	// ChunkSize, if positive, splits uploads larger than ChunkSize bytes
+	// into chunks sent in separate requests, so an interrupted upload can
+	// be resumed.
+	ChunkSize int64 `url:"-"`
+
+	// UploadID and Offset resume an interrupted chunked upload; they are
+	// reported by UploadInterruptedError.
+	UploadID string `url:"upload_id,omitempty"`
+	Offset   int64  `url:"-"`
 }
 
 // RawType represents type of raw format of a request instead of JSON.
@@ -120,6 +166,19 @@ type RawOptions struct {
 	Type RawType
 }
 
+// acceptHeader returns the media type requesting the raw format of opt,
+// derived from mediaTypeV3, e.g. application/vnd.pavedroad.v3.diff.
+func (opt RawOptions) acceptHeader() (string, error) {
+	base := strings.TrimSuffix(mediaTypeV3, "+json")
+	switch opt.Type {
+	case Diff:
+		return base + ".diff", nil
+	case Patch:
+		return base + ".patch", nil
+	}
+	return "", fmt.Errorf("unsupported raw type %d", opt.Type)
+}
+
 // addOptions adds the parameters in opt as URL query parameters to s. opt
 // must be a struct whose fields may contain "url" tags.
 func addOptions(s string, opt interface{}) (string, error) {
@@ -153,12 +212,96 @@ func NewClient(httpClient *http.Client) *Client {
 	baseURL, _ := url.Parse(defaultBaseURL)
 	uploadURL, _ := url.Parse(uploadBaseURL)
 
-	c := &Client{client: httpClient, BaseURL: baseURL, UserAgent: userAgent, UploadURL: uploadURL}
+	c := &Client{client: httpClient, BaseURL: baseURL, UserAgent: userAgent, UploadURL: uploadURL, rate: &rateState{}}
+	c.initServices()
+	return c
+}
+
+// initServices points the services of c at c.
+func (c *Client) initServices() {
 	c.common.client = c
 	c.Token = (*TokensService)(&c.common)
 	c.UserIdMapper = (*UserIdMappersService)(&c.common)
-	//c.Users = (*UsersService)(&c.common)
-	return c
+	c.Users = (*UsersService)(&c.common)
+	c.Repositories = (*RepositoriesService)(&c.common)
+	c.GitHub = (*GitHubService)(&c.common)
+	c.Uploads = (*UploadsService)(&c.common)
+}
+
+// Namespace returns a view of c whose services target the PavedRoad
+// namespace name, i.e. /api/v1/namespace/{name}/. The view shares the
+// http.Client, and therefore the connection pool, and the settings of c.
+// Changes made to c after the call are not reflected in the view.
+//
+// name is escaped in the URL. If name is empty, "." or "..", or contains a
+// "/", every request of the view fails.
+func (c *Client) Namespace(name string) *Client {
+	base, err := withNamespace(c.BaseURL, name)
+	if err != nil {
+		base = c.BaseURL
+	}
+	nc := &Client{
+		client:      c.client,
+		BaseURL:     base,
+		UploadURL:   c.UploadURL,
+		UserAgent:   c.UserAgent,
+		RetryPolicy: c.RetryPolicy,
+
+		EnforceRateLimit: c.EnforceRateLimit,
+		KeyProvider:      c.KeyProvider,
+		rate:             c.rate,
+		namespaceErr:     err,
+	}
+	nc.initServices()
+	return nc
+}
+
+// withNamespace returns a copy of base with its namespace segment replaced by
+// namespace, escaped. A namespace segment is appended if base does not have
+// one. It fails for names that would change another part of the path.
+func withNamespace(base *url.URL, namespace string) (*url.URL, error) {
+	if namespace == "" || namespace == "." || namespace == ".." || strings.Contains(namespace, "/") {
+		return nil, fmt.Errorf("invalid namespace %q", namespace)
+	}
+
+	u := *base
+	segments := strings.Split(strings.TrimSuffix(u.EscapedPath(), "/"), "/")
+
+	ns := strings.TrimSuffix(namespaceID, "/")
+	i := len(segments) - 2
+	for ; i >= 0; i-- {
+		if segments[i] == ns {
+			break
+		}
+	}
+	if i >= 0 {
+		segments = segments[:i]
+	}
+	segments = append(segments, ns, url.PathEscape(namespace))
+
+	escaped := strings.Join(segments, "/") + "/"
+	path, err := url.PathUnescape(escaped)
+	if err != nil {
+		return nil, err
+	}
+	u.Path, u.RawPath = path, escaped
+	return &u, nil
+}
+
+// namespaceOf returns the namespace segment of base, or "" if it has none.
+func namespaceOf(base *url.URL) string {
+	segments := strings.Split(strings.TrimSuffix(base.EscapedPath(), "/"), "/")
+	ns := strings.TrimSuffix(namespaceID, "/")
+	for i := len(segments) - 2; i >= 0; i-- {
+		if segments[i] == ns {
+			name, err := url.PathUnescape(segments[i+1])
+			if err != nil {
+				return segments[i+1]
+			}
+			return name
+		}
+	}
+	return ""
 }
 
 // NewRequest creates an API request. A relative URL can be provided in urlStr,
@@ -167,6 +310,9 @@ func NewClient(httpClient *http.Client) *Client {
 // specified, the value pointed to by body is JSON encoded and included as the
 // request body.
 func (c *Client) NewRequest(method, urlStr string, body interface{}) (*http.Request, error) {
+	if c.namespaceErr != nil {
+		return nil, c.namespaceErr
+	}
 	if !strings.HasSuffix(c.BaseURL.Path, "/") {
 		return nil, fmt.Errorf("BaseURL must have a trailing slash, but %q does not", c.BaseURL)
 	}
@@ -243,6 +389,9 @@ type Response struct {
 	PrevPage  int
 	FirstPage int
 	LastPage  int
+
+	// Rate is the rate limit reported by the response headers, if any.
+	Rate Rate
 }
 
 // newResponse creates a new Response for the provided http.Response.
@@ -250,6 +399,7 @@ type Response struct {
 func newResponse(r *http.Response) *Response {
 	response := &Response{Response: r}
 	response.populatePageValues()
+	response.Rate = parseRate(r)
 	return response
 }
 
@@ -301,11 +451,82 @@ func (r *Response) populatePageValues() {
 // JSON decoded and stored in the value pointed to by v, or returned as an
 // error if an API error has occurred. If v implements the io.Writer
 // interface, the raw response body will be written to v, without attempting to
-// first decode it.
+// first decode it; an error is returned if the body ends early, and the
+// request is then not retried.
+//
+// If the Client has a RetryPolicy, failed attempts it considers transient are
+// repeated after a backoff; see RetryPolicy.
 //
 // The provided ctx must be non-nil. If it is canceled or times out,
 // ctx.Err() will be returned.
 func (c *Client) Do(ctx context.Context, req *http.Request, v interface{}) (*Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := c.doOnce(ctx, req, v)
+		if err == nil {
+			return resp, nil
+		}
+		if ctx.Err() != nil {
+			return resp, ctx.Err()
+		}
+		var berr *bodyError
+		if errors.As(err, &berr) || !c.RetryPolicy.shouldRetry(attempt, req, resp, err) {
+			return resp, err
+		}
+
+		if err := rewindBody(req); err != nil {
+			return resp, err
+		}
+
+		t := time.NewTimer(c.RetryPolicy.delay(attempt, resp))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return resp, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// doOnce makes a single attempt at sending req. See Do.
+func (c *Client) doOnce(ctx context.Context, req *http.Request, v interface{}) (*Response, error) {
+	response, err := c.bareDo(ctx, req)
+	if err != nil {
+		return response, err
+	}
+	defer response.Body.Close()
+
+	if v != nil {
+		if w, ok := v.(io.Writer); ok {
+			if _, cerr := io.Copy(w, response.Body); cerr != nil {
+				err = &bodyError{cerr}
+			}
+		} else {
+			decErr := json.NewDecoder(response.Body).Decode(v)
+			if decErr == io.EOF {
+				decErr = nil // ignore EOF errors caused by empty response body
+			}
+			if decErr != nil {
+				err = decErr
+			}
+		}
+	}
+
+	return response, err
+}
+
+// bareDo makes a single attempt at sending req, like doOnce, but returns
+// a successful response with its body unread; the caller must close it.
+func (c *Client) bareDo(ctx context.Context, req *http.Request) (*Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if c.EnforceRateLimit {
+		if err := c.rate.check(req); err != nil {
+			return &Response{Response: err.Response, Rate: err.Rate}, err
+		}
+	}
+
 	resp, err := c.client.Do(req)
 	if err != nil {
 		// If we got an error, and the context has been canceled,
@@ -326,12 +547,13 @@ func (c *Client) Do(ctx context.Context, req *http.Request, v interface{}) (*Res
 
 		return nil, err
 	}
-	defer resp.Body.Close()
 
 	response := newResponse(resp)
+	c.rate.update(resp)
 
 	err = CheckResponse(resp)
 	if err != nil {
+		defer resp.Body.Close()
 		// Special case for AcceptedErrors. If an AcceptedError
 		// has been encountered, the response's payload will be
 		// added to the AcceptedError and returned.
@@ -351,21 +573,22 @@ func (c *Client) Do(ctx context.Context, req *http.Request, v interface{}) (*Res
 		return response, err
 	}
 
-	if v != nil {
-		if w, ok := v.(io.Writer); ok {
-			io.Copy(w, resp.Body)
-		} else {
-			decErr := json.NewDecoder(resp.Body).Decode(v)
-			if decErr == io.EOF {
-				decErr = nil // ignore EOF errors caused by empty response body
-			}
-			if decErr != nil {
-				err = decErr
-			}
-		}
-	}
+	return response, nil
+}
 
-	return response, err
+// bodyError reports that a response body could not be copied in full to
+// the io.Writer given to Do. Such requests are not retried, since part of
+// the body was already written.
+type bodyError struct {
+	err error
+}
+
+func (e *bodyError) Error() string {
+	return "reading response body: " + e.err.Error()
+}
+
+func (e *bodyError) Unwrap() error {
+	return e.err
 }
 
 /*
@@ -399,7 +622,7 @@ func (r *ErrorResponse) Error() string {
 // the information needed and cache it.
 // Technically, 202 Accepted is not a real error, it's just used to
 // indicate that results are not ready yet, but should be available soon.
-// The request can be repeated after some time.
+// The request can be repeated after some time; Client.DoUntilReady does so.
 type AcceptedError struct {
 	// Raw contains the response body.
 	Raw []byte
@@ -409,35 +632,77 @@ func (*AcceptedError) Error() string {
 	return "job scheduled on PavedRoad side; try again later"
 }
 
-// sanitizeURL redacts the client_secret parameter from the URL which may be
-// exposed to the user.
+// secretParams lists the query parameters redacted by sanitizeURL.
+var secretParams = []string{"client_secret", "access_token", "token", "password"}
+
+// secretKeyResources lists the resources whose keys, the last segment of
+// their paths, are secret and redacted by sanitizeURL.
+var secretKeyResources = []string{mapperResource}
+
+// sanitizeURL redacts secret parameters, such as client_secret, the
+// password of the user info and the secret keys of resources, such as
+// the credentials of user ID mappers, from the URL which may be exposed to
+// the user.
 func sanitizeURL(uri *url.URL) *url.URL {
 	if uri == nil {
 		return nil
 	}
-	params := uri.Query()
-	if len(params.Get("client_secret")) > 0 {
-		params.Set("client_secret", "REDACTED")
-		uri.RawQuery = params.Encode()
+	// Leave the URL of the request untouched.
+	u := *uri
+	if p, ok := redactKeys(u.EscapedPath()); ok {
+		u.Path, _ = url.PathUnescape(p)
+		if u.RawPath = ""; u.EscapedPath() != p {
+			u.RawPath = p
+		}
+	}
+	params := u.Query()
+	var changed bool
+	for _, p := range secretParams {
+		if len(params.Get(p)) > 0 {
+			params.Set(p, redacted)
+			changed = true
+		}
+	}
+	if changed {
+		u.RawQuery = params.Encode()
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	return &u
+}
+
+// redactKeys redacts the secret key following a resource of
+// secretKeyResources in the escaped path p. It reports whether p changed.
+func redactKeys(p string) (string, bool) {
+	segs := strings.Split(p, "/")
+	var changed bool
+	for i := 0; i+1 < len(segs); i++ {
+		for _, r := range secretKeyResources {
+			if segs[i] == r && segs[i+1] != "" {
+				segs[i+1] = redacted
+				changed = true
+			}
+		}
 	}
-	return uri
+	return strings.Join(segs, "/"), changed
 }
 
 /*
 An Error reports more details on an individual error in an ErrorResponse.
 These are the possible validation error codes:
 
-    missing:
-        resource does not exist
-    missing_field:
-        a required field on a resource has not been set
-    invalid:
-        the formatting of a field is invalid
-    already_exists:
-        another resource has the same valid as this field
-    custom:
-        some resources return this, additional information is
-        set in the Message field of the Error
+	missing:
+	    resource does not exist
+	missing_field:
+	    a required field on a resource has not been set
+	invalid:
+	    the formatting of a field is invalid
+	already_exists:
+	    another resource has the same valid as this field
+	custom:
+	    some resources return this, additional information is
+	    set in the Message field of the Error
 
 PavedRoad API docs: https://developer.pavedroad.io/v1/#client-errors
 */
@@ -460,8 +725,10 @@ func (e *Error) Error() string {
 // body, or a JSON response body that maps to ErrorResponse. Any other
 // response body will be silently ignored.
 //
+// The error type will be *RateLimitError for 429 Too Many Requests and for
+// 403 Forbidden responses reporting an exhausted rate limit,
 // *AcceptedError for 202 Accepted status codes,
-// and *TwoFactorAuthError for two-factor authentication errors.
+// and *ErrorResponse otherwise.
 func CheckResponse(r *http.Response) error {
 	if r.StatusCode == http.StatusAccepted {
 		return &AcceptedError{}
@@ -474,6 +741,15 @@ func CheckResponse(r *http.Response) error {
 	if err == nil && data != nil {
 		json.Unmarshal(data, errorResponse)
 	}
+
+	if r.StatusCode == http.StatusTooManyRequests ||
+		r.StatusCode == http.StatusForbidden && r.Header.Get(headerRateRemaining) == "0" {
+		return &RateLimitError{
+			Rate:     parseRate(r),
+			Response: errorResponse.Response,
+			Message:  errorResponse.Message,
+		}
+	}
 	return errorResponse
 }
 
@@ -487,7 +763,8 @@ func parseBoolResponse(err error) (bool, error) {
 		return true, nil
 	}
 
-	if err, ok := err.(*ErrorResponse); ok && err.Response.StatusCode == http.StatusNotFound {
+	var errResp *ErrorResponse
+	if errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusNotFound {
 		// Simply false. In this one case, we do not pass the error through.
 		return false, nil
 	}
@@ -523,14 +800,19 @@ func (t *BasicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error
 // additionally supports users who have two-factor authentication enabled.
 type BasicAuthTransport struct {
 	Username string // PavedRoad username
-	Password string // PavedRoad password
-	OTP      string // one-time password for users with two-factor auth enabled
+	Password string `pr:"secret"` // PavedRoad password
+	OTP      string `pr:"secret"` // one-time password for users with two-factor auth enabled
 
 	// Transport is the underlying HTTP transport to use when making requests.
 	// It will default to http.DefaultTransport if nil.
 	Transport http.RoundTripper
 }
 
+// String returns t with Password and OTP redacted.
+func (t BasicAuthTransport) String() string {
+	return Stringify(t)
+}
+
 // Client returns an *http.Client that makes requests that are authenticated
 // using HTTP Basic Authentication.
 func (t *BasicAuthTransport) Client() *http.Client {
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -172,6 +174,68 @@ func TestNewClient(t *testing.T) {
 	}
 }
 
+func TestClient_Namespace(t *testing.T) {
+	c := NewClient(nil)
+	c.RetryPolicy = NewRetryPolicy(2)
+	nc := c.Namespace("acme")
+
+	if got, want := nc.BaseURL.String(), "https://api.pavedroad.io/api/v1/namespace/acme/"; got != want {
+		t.Errorf("Namespace BaseURL is %v, want %v", got, want)
+	}
+	if got, want := c.BaseURL.String(), defaultBaseURL; got != want {
+		t.Errorf("Namespace modified BaseURL to %v, want %v", got, want)
+	}
+	if nc.client != c.client {
+		t.Error("Namespace returned a client with a different http.Client")
+	}
+	if nc.RetryPolicy != c.RetryPolicy {
+		t.Error("Namespace did not keep the RetryPolicy")
+	}
+	if nc.Token.client != nc {
+		t.Error("Namespace services do not use the namespaced client")
+	}
+}
+
+func TestWithNamespace(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"https://example.com/api/v1/namespace/a/", "https://example.com/api/v1/namespace/b/"},
+		{"https://example.com/api/v1/namespace/a", "https://example.com/api/v1/namespace/b/"},
+		{"https://example.com/api/v1/", "https://example.com/api/v1/namespace/b/"},
+	}
+	for _, tt := range tests {
+		in, _ := url.Parse(tt.in)
+		got, err := withNamespace(in, "b")
+		if err != nil || got.String() != tt.want {
+			t.Errorf("withNamespace(%v) returned %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestWithNamespace_escaped(t *testing.T) {
+	in, _ := url.Parse("https://example.com/api/v1/namespace/a/")
+	got, err := withNamespace(in, "a b?c")
+	if err != nil {
+		t.Fatalf("withNamespace returned error: %v", err)
+	}
+	if want := "https://example.com/api/v1/namespace/a%20b%3Fc/"; got.String() != want {
+		t.Errorf("withNamespace returned %v, want %v", got, want)
+	}
+	if ns := namespaceOf(got); ns != "a b?c" {
+		t.Errorf("namespaceOf returned %q, want %q", ns, "a b?c")
+	}
+}
+
+func TestClient_Namespace_invalid(t *testing.T) {
+	for _, name := range []string{"", ".", "..", "a/../b"} {
+		nc := NewClient(nil).Namespace(name)
+		if _, err := nc.NewRequest("GET", "prTokens/", nil); err == nil {
+			t.Errorf("NewRequest in namespace %q returned no error", name)
+		}
+	}
+}
+
 func TestNewRequest(t *testing.T) {
 	c := NewClient(nil)
 
@@ -409,6 +473,45 @@ func TestDo_httpError(t *testing.T) {
 	}
 }
 
+func TestDo_canceled(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Request sent with a canceled context.")
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req, _ := client.NewRequest("GET", ".", nil)
+	if _, err := client.Do(ctx, req, nil); err != context.Canceled {
+		t.Errorf("Do returned error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestDo_truncatedStream(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+	client.RetryPolicy = NewRetryPolicy(3)
+
+	calls := 0
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Length", "10")
+		fmt.Fprint(w, "01234")
+	})
+
+	req, _ := client.NewRequest("GET", ".", nil)
+	var buf bytes.Buffer
+	_, err := client.Do(context.Background(), req, &buf)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Do returned error %v, want io.ErrUnexpectedEOF", err)
+	}
+	if calls != 1 || buf.String() != "01234" {
+		t.Errorf("Do made %d calls and wrote %q, want a single call", calls, buf.String())
+	}
+}
+
 // Test handling of an error caused by the internal http client's Do()
 // function.
 func TestDo_redirectLoop(t *testing.T) {
@@ -459,6 +562,11 @@ func TestSanitizeURL(t *testing.T) {
 		{"/?a=b", "/?a=b"},
 		{"/?a=b&client_secret=secret", "/?a=b&client_secret=REDACTED"},
 		{"/?a=b&client_id=id&client_secret=secret", "/?a=b&client_id=id&client_secret=REDACTED"},
+		{"/?access_token=t&a=b", "/?a=b&access_token=REDACTED"},
+		{"https://u:p@h/?a=b", "https://u:REDACTED@h/?a=b"},
+		{"/ns/a%2Fb/prUserIdMappers/jdoe", "/ns/a%2Fb/prUserIdMappers/REDACTED"},
+		{"/prUserIdMappers/jdoe/history?a=b", "/prUserIdMappers/REDACTED/history?a=b"},
+		{"/prUserIdMappersLIST/?a=b", "/prUserIdMappersLIST/?a=b"},
 	}
 
 	for _, tt := range tests {
@@ -468,6 +576,26 @@ func TestSanitizeURL(t *testing.T) {
 		if got := sanitizeURL(inURL); !reflect.DeepEqual(got, want) {
 			t.Errorf("sanitizeURL(%v) returned %v, want %v", tt.in, got, want)
 		}
+		if inURL.String() != tt.in {
+			t.Errorf("sanitizeURL(%v) changed its input to %v", tt.in, inURL)
+		}
+	}
+}
+
+func TestErrorResponse_Error_mapperKey(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+mapperResource+"/jdoe", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "Forbidden"}`, http.StatusForbidden)
+	})
+
+	_, _, err := client.UserIdMapper.Get(context.Background(), "jdoe")
+	if err == nil {
+		t.Fatal("Expected error to be returned.")
+	}
+	if strings.Contains(err.Error(), "jdoe") || !strings.Contains(err.Error(), mapperResource+"/"+redacted) {
+		t.Errorf("UserIdMappers.Get returned error %q, want the credential redacted", err)
 	}
 }
 
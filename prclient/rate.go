@@ -0,0 +1,143 @@
+package prclient
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate represents the rate limit for the current client.
+type Rate struct {
+	// The number of requests per hour the client is currently limited to.
+	Limit int `json:"limit"`
+
+	// The number of remaining requests the client can make this hour.
+	Remaining int `json:"remaining"`
+
+	// The time at which the current rate limit will reset.
+	Reset Timestamp `json:"reset"`
+}
+
+func (r Rate) String() string {
+	return Stringify(r)
+}
+
+// parseRate parses the rate related headers.
+func parseRate(r *http.Response) Rate {
+	var rate Rate
+	if limit := r.Header.Get(headerRateLimit); limit != "" {
+		rate.Limit, _ = strconv.Atoi(limit)
+	}
+	if remaining := r.Header.Get(headerRateRemaining); remaining != "" {
+		rate.Remaining, _ = strconv.Atoi(remaining)
+	}
+	if reset := r.Header.Get(headerRateReset); reset != "" {
+		if v, _ := strconv.ParseInt(reset, 10, 64); v != 0 {
+			rate.Reset = Timestamp{time.Unix(v, 0)}
+		}
+	}
+	return rate
+}
+
+// RateLimitError occurs when PavedRoad returns 429 Too Many Requests, or 403
+// Forbidden with a rate limit remaining count of 0, or when the client
+// refuses to send a request because the rate limit is known to be exhausted
+// (see Client.EnforceRateLimit).
+type RateLimitError struct {
+	Rate     Rate           // Rate specifies last known rate limit for the client
+	Response *http.Response // HTTP response that caused this error
+	Message  string         `json:"message"` // error message
+}
+
+func (r *RateLimitError) Error() string {
+	return fmt.Sprintf("%v %v: %d %v %v",
+		r.Response.Request.Method, sanitizeURL(r.Response.Request.URL),
+		r.Response.StatusCode, r.Message, formatRateReset(time.Until(r.Rate.Reset.Time)))
+}
+
+// formatRateReset formats d to look like "[rate reset in 2s]" or
+// "[rate reset in 87m02s]" for the positive durations. And like "[rate limit
+// was reset 87m02s ago]" for the negative cases.
+func formatRateReset(d time.Duration) string {
+	isNegative := d < 0
+	if isNegative {
+		d *= -1
+	}
+	secondsTotal := int(0.5 + d.Seconds())
+	minutes := secondsTotal / 60
+	seconds := secondsTotal - minutes*60
+
+	var timeString string
+	if minutes > 0 {
+		timeString = fmt.Sprintf("%dm%02ds", minutes, seconds)
+	} else {
+		timeString = fmt.Sprintf("%ds", seconds)
+	}
+
+	if isNegative {
+		return fmt.Sprintf("[rate limit was reset %v ago]", timeString)
+	}
+	return fmt.Sprintf("[rate reset in %v]", timeString)
+}
+
+// rateState records the last rate limit reported by PavedRoad.
+type rateState struct {
+	mu    sync.Mutex
+	rate  Rate
+	known bool
+}
+
+// Rate returns the rate limit reported by the most recent response that
+// carried rate limit headers. The zero Rate is returned if there was none.
+func (c *Client) Rate() Rate {
+	if c.rate == nil {
+		return Rate{}
+	}
+	c.rate.mu.Lock()
+	defer c.rate.mu.Unlock()
+	return c.rate.rate
+}
+
+// update records the rate limit reported by r, if any.
+func (s *rateState) update(r *http.Response) {
+	if s == nil || r.Header.Get(headerRateRemaining) == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rate = parseRate(r)
+	s.known = true
+}
+
+// check returns a *RateLimitError, without making a network call, if the
+// last known rate limit is exhausted and has not been reset yet.
+func (s *rateState) check(req *http.Request) *RateLimitError {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	rate, known := s.rate, s.known
+	s.mu.Unlock()
+
+	if !known || rate.Remaining > 0 || !time.Now().Before(rate.Reset.Time) {
+		return nil
+	}
+
+	// Create a fake response.
+	resp := &http.Response{
+		Status:     http.StatusText(http.StatusForbidden),
+		StatusCode: http.StatusForbidden,
+		Request:    req,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}
+	return &RateLimitError{
+		Rate:     rate,
+		Response: resp,
+		Message:  fmt.Sprintf("API rate limit of %v still exceeded until %v, not making remote request.", rate.Limit, rate.Reset.Time),
+	}
+}
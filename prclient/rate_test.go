@@ -0,0 +1,121 @@
+package prclient
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDo_rateLimit(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(headerRateLimit, "60")
+		w.Header().Set(headerRateRemaining, "59")
+		w.Header().Set(headerRateReset, "1372700873")
+	})
+
+	req, _ := client.NewRequest("GET", ".", nil)
+	resp, err := client.Do(context.Background(), req, nil)
+	if err != nil {
+		t.Errorf("Do returned unexpected error: %v", err)
+	}
+	want := Rate{Limit: 60, Remaining: 59, Reset: Timestamp{time.Date(2013, time.July, 1, 17, 47, 53, 0, time.UTC).Local()}}
+	if got := resp.Rate; got.Limit != want.Limit || got.Remaining != want.Remaining || !got.Reset.Equal(want.Reset) {
+		t.Errorf("Response.Rate = %v, want %v", got, want)
+	}
+	if got := client.Rate(); got.Remaining != want.Remaining {
+		t.Errorf("Client.Rate() = %v, want %v", got, want)
+	}
+}
+
+func TestCheckResponse_rateLimit(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusForbidden} {
+		res := &http.Response{
+			Request:    &http.Request{},
+			StatusCode: status,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader(`{"message":"m"}`)),
+		}
+		res.Header.Set(headerRateLimit, "60")
+		res.Header.Set(headerRateRemaining, "0")
+		res.Header.Set(headerRateReset, "243424")
+
+		err, ok := CheckResponse(res).(*RateLimitError)
+		if !ok {
+			t.Fatalf("CheckResponse(%d) did not return a *RateLimitError", status)
+		}
+		if err.Message != "m" || err.Rate.Limit != 60 || err.Rate.Reset.Unix() != 243424 {
+			t.Errorf("CheckResponse(%d) returned %+v", status, err)
+		}
+	}
+}
+
+func TestCheckResponse_forbiddenWithoutRateLimit(t *testing.T) {
+	res := &http.Response{
+		Request:    &http.Request{},
+		StatusCode: http.StatusForbidden,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}
+	if _, ok := CheckResponse(res).(*ErrorResponse); !ok {
+		t.Errorf("CheckResponse did not return an *ErrorResponse")
+	}
+}
+
+func TestDo_enforceRateLimit(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+	client.EnforceRateLimit = true
+
+	reset := time.Now().Add(time.Minute).Unix()
+	requests := 0
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set(headerRateLimit, "60")
+		w.Header().Set(headerRateRemaining, "0")
+		w.Header().Set(headerRateReset, fmt.Sprint(reset))
+	})
+
+	req, _ := client.NewRequest("GET", ".", nil)
+	if _, err := client.Do(context.Background(), req, nil); err != nil {
+		t.Fatalf("Do returned unexpected error: %v", err)
+	}
+
+	// The limit is now known to be exhausted: no request should be sent.
+	req, _ = client.NewRequest("GET", ".", nil)
+	resp, err := client.Do(context.Background(), req, nil)
+	rerr, ok := err.(*RateLimitError)
+	if !ok {
+		t.Fatalf("Do returned error %#v, want *RateLimitError", err)
+	}
+	if requests != 1 {
+		t.Errorf("server saw %d requests, want 1", requests)
+	}
+	if resp.StatusCode != http.StatusForbidden || rerr.Rate.Reset.Unix() != reset {
+		t.Errorf("Do returned %v, %v", resp.StatusCode, rerr)
+	}
+	if rerr.Error() == "" {
+		t.Errorf("Expected non-empty RateLimitError.Error()")
+	}
+}
+
+func TestFormatRateReset(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{2 * time.Second, "[rate reset in 2s]"},
+		{87*time.Minute + 2*time.Second, "[rate reset in 87m02s]"},
+		{-90 * time.Second, "[rate limit was reset 1m30s ago]"},
+	}
+	for _, tt := range tests {
+		if got := formatRateReset(tt.d); got != tt.want {
+			t.Errorf("formatRateReset(%v) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Repository implements access to prRepository microservice which stores the
+source repositories registered with PavedRoad.
+
+HTTP verbs are translated into the following function calls:
+
+Verbs to Functions
+------   ---------
+POST     Create
+GET      Get
+GET/     List resources
+PUT      Replace
+DELETE   Delete
+PATCH    Edit, Patch
+*/
+package prclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+)
+
+// RepositoriesService handles communication with the repository related
+// methods of the PavedRoad API.
+type RepositoriesService service
+
+// Repository data structure for repository registration
+type Repository struct {
+	APIVersion    string           `json:"apiVersion"`
+	Kind          string           `json:"kind"`
+	Metadata      ResourceMetadata `json:"metadata"`
+	Owner         string           `json:"owner"`
+	VCSURL        string           `json:"vcsURL"`
+	DefaultBranch string           `json:"defaultBranch"`
+	TokenUID      string           `json:"tokenUID"` // UID of the Token used to access the repository
+	Created       *Timestamp       `json:"created,omitempty"`
+	Updated       *Timestamp       `json:"updated,omitempty"`
+}
+
+func (r Repository) String() string {
+	return Stringify(r)
+}
+
+// resource returns the generic client for prRepository resources.
+func (s *RepositoriesService) resource() *ResourceClient[Repository] {
+	return NewResourceClient(s.client, repositoryResource, "UUID", func(r *Repository) string {
+		return r.Metadata.UID
+	})
+}
+
+// Create a repository
+// PavedRoad API endpoint /prRepository/
+func (s *RepositoriesService) Create(ctx context.Context, newRepository Repository) (*Repository, *Response, error) {
+	return s.resource().Create(ctx, &newRepository)
+}
+
+// Get fetches a repository based on a UUID.
+// PavedRoad API endpoint /prRepository/uuid.
+func (s *RepositoriesService) Get(ctx context.Context, uuid string) (*Repository, *Response, error) {
+	return s.resource().Get(ctx, uuid)
+}
+
+// GetToken fetches the Token referenced by repo.TokenUID.
+// PavedRoad API endpoint /prTokens/uuid.
+func (s *RepositoriesService) GetToken(ctx context.Context, repo *Repository) (*Token, *Response, error) {
+	if repo == nil {
+		return nil, nil, errors.New("repository is required")
+	}
+	if repo.TokenUID == "" {
+		return nil, nil, errors.New("repository does not reference a token")
+	}
+	return s.client.Token.Get(ctx, repo.TokenUID)
+}
+
+// Delete a repository using a UUID.
+// PavedRoad API endpoint /prRepository/uuid.
+func (s *RepositoriesService) Delete(ctx context.Context, uuid string) (*Response, error) {
+	return s.resource().Delete(ctx, uuid)
+}
+
+// Edit a repository.
+// The whole repository is sent, so zero values overwrite the stored ones;
+// use Patch to change only some fields.
+// PavedRoad API endpoint /prRepository/uuid.
+func (s *RepositoriesService) Edit(ctx context.Context, repo *Repository, uuid string) (*Repository, *Response, error) {
+	return s.resource().Edit(ctx, repo, uuid)
+}
+
+// Patch applies a MergePatch or a JSONPatch to a repository.
+// PavedRoad API endpoint /prRepository/uuid.
+func (s *RepositoriesService) Patch(ctx context.Context, uuid string, patch PatchDocument) (*Repository, *Response, error) {
+	return s.resource().Patch(ctx, uuid, patch)
+}
+
+// Replace a repository.
+// PavedRoad API endpoint /prRepository/uuid.
+func (s *RepositoriesService) Replace(ctx context.Context, repo *Repository, uuid string) (*Repository, *Response, error) {
+	return s.resource().Replace(ctx, repo, uuid)
+}
+
+// GetChangesRaw returns the changes of a repository in the raw format
+// given by opt, e.g. a diff between its versions.
+// PavedRoad API endpoint /prRepository/uuid/changes.
+func (s *RepositoriesService) GetChangesRaw(ctx context.Context, uuid string, opt RawOptions) (string, *Response, error) {
+	var buf bytes.Buffer
+	resp, err := s.StreamChangesRaw(ctx, uuid, opt, &buf)
+	if err != nil {
+		return "", resp, err
+	}
+	return buf.String(), resp, nil
+}
+
+// StreamChangesRaw writes the changes of a repository to w in the raw
+// format given by opt, without buffering it.
+// PavedRoad API endpoint /prRepository/uuid/changes.
+func (s *RepositoriesService) StreamChangesRaw(ctx context.Context, uuid string, opt RawOptions, w io.Writer) (*Response, error) {
+	return s.resource().GetRaw(ctx, uuid, "changes", opt, w)
+}
+
+// RepositoryListOptions specifies optional parameters to the
+// RepositoriesService.List and RepositoriesService.ListAll methods.
+type RepositoryListOptions struct {
+	// Only list the repositories of this owner
+	Owner string `url:"owner,omitempty"`
+
+	// UID of the last repository seen
+	Since string `url:"since,omitempty"`
+
+	// Note: when the server does not return Link headers, pagination is
+	// powered by the Since parameter and ListOptions.Page has no effect.
+	ListOptions
+}
+
+// Advance implements Pager.
+func (o *RepositoryListOptions) Advance(resp *Response, n int, last string) bool {
+	return advancePage(&o.ListOptions, &o.Since, resp, n, last)
+}
+
+// List lists one page of PavedRoad repositories.
+// PavedRoad API endpoint /prRepositoryLIST/
+func (s *RepositoriesService) List(ctx context.Context, opt *RepositoryListOptions) ([]*Repository, *Response, error) {
+	return s.resource().List(ctx, opt)
+}
+
+// ListAll walks every page of PavedRoad repositories starting at opt and
+// returns them all. See ResourceClient.ListAll.
+func (s *RepositoriesService) ListAll(ctx context.Context, opt *RepositoryListOptions) ([]*Repository, error) {
+	var o RepositoryListOptions
+	if opt != nil {
+		o = *opt
+	}
+	return s.resource().ListAll(ctx, &o)
+}
+
+// ListByOwner lists every repository registered by owner.
+// PavedRoad API endpoint /prRepositoryLIST/?owner=owner
+func (s *RepositoriesService) ListByOwner(ctx context.Context, owner string) ([]*Repository, error) {
+	if owner == "" {
+		return nil, errors.New("owner is required")
+	}
+	return s.ListAll(ctx, &RepositoryListOptions{Owner: owner})
+}
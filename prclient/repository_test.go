@@ -0,0 +1,139 @@
+package prclient
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var blankRepositoryJSON = `{"apiVersion":"1","kind":"","metadata":{"name":"","namespace":"","uid":""},"owner":"","vcsURL":"","defaultBranch":"","tokenUID":""}`
+
+func TestRepository_marshall(t *testing.T) {
+	r := &Repository{APIVersion: "1"}
+	testJSONMarshal(t, r, blankRepositoryJSON)
+}
+
+func TestRepositoriesService_Get(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+repositoryResource+"/1234", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, blankRepositoryJSON)
+	})
+
+	repo, _, err := client.Repositories.Get(context.Background(), "1234")
+	if err != nil {
+		t.Errorf("Repositories.Get returned error: %v", err)
+	}
+
+	want := &Repository{APIVersion: "1"}
+	if !cmp.Equal(repo, want) {
+		t.Errorf("Repositories.Get returned %+v, want %+v", repo, want)
+	}
+}
+
+func TestRepositoriesService_Create(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+repositoryResource+"/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		fmt.Fprint(w, `{"metadata":{"uid":"r-1","name":"clients"},"vcsURL":"https://github.com/pavedroad-io/clients"}`)
+	})
+
+	input := Repository{Metadata: ResourceMetadata{Name: "clients"}, VCSURL: "https://github.com/pavedroad-io/clients"}
+	repo, _, err := client.Repositories.Create(context.Background(), input)
+	if err != nil {
+		t.Errorf("Repositories.Create returned error: %v", err)
+	}
+	if repo.Metadata.UID != "r-1" {
+		t.Errorf("Repositories.Create returned %+v, want UID r-1", repo)
+	}
+}
+
+func TestRepositoriesService_Delete(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+repositoryResource+"/1", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "DELETE")
+	})
+
+	if _, err := client.Repositories.Delete(context.Background(), "1"); err != nil {
+		t.Errorf("Repositories.Delete returned error: %v", err)
+	}
+}
+
+func TestRepositoriesService_ListByOwner(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+repositoryResource+"LIST/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		testFormValues(t, r, values{"owner": "pavedroad-io"})
+		fmt.Fprint(w, `[{"owner":"pavedroad-io"}]`)
+	})
+
+	repos, err := client.Repositories.ListByOwner(context.Background(), "pavedroad-io")
+	if err != nil {
+		t.Errorf("Repositories.ListByOwner returned error: %v", err)
+	}
+
+	want := []*Repository{{Owner: "pavedroad-io"}}
+	if !cmp.Equal(repos, want) {
+		t.Errorf("Repositories.ListByOwner returned %+v, want %+v", repos, want)
+	}
+
+	if _, err := client.Repositories.ListByOwner(context.Background(), ""); err == nil {
+		t.Error("Expected error to be returned for an empty owner.")
+	}
+}
+
+func TestRepositoriesService_GetToken(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+tokenResource+"/t-1", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, `{"metadata":{"uid":"t-1"}}`)
+	})
+
+	token, _, err := client.Repositories.GetToken(context.Background(), &Repository{TokenUID: "t-1"})
+	if err != nil {
+		t.Errorf("Repositories.GetToken returned error: %v", err)
+	}
+	if token.Metadata.UID != "t-1" {
+		t.Errorf("Repositories.GetToken returned %+v, want UID t-1", token)
+	}
+
+	if _, _, err := client.Repositories.GetToken(context.Background(), &Repository{}); err == nil {
+		t.Error("Expected error to be returned for a repository without token.")
+	}
+	if _, _, err := client.Repositories.GetToken(context.Background(), nil); err == nil {
+		t.Error("Expected error to be returned for a nil repository.")
+	}
+}
+
+func TestRepositoriesService_StreamChangesRaw(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+repositoryResource+"/1/changes", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		testHeader(t, r, "Accept", "application/vnd.pavedroad.v3.patch")
+		fmt.Fprint(w, "From 1 Mon Sep 17 00:00:00 2001\n")
+	})
+
+	var buf bytes.Buffer
+	if _, err := client.Repositories.StreamChangesRaw(context.Background(), "1", RawOptions{Type: Patch}, &buf); err != nil {
+		t.Fatalf("Repositories.StreamChangesRaw returned error: %v", err)
+	}
+	if want := "From 1 Mon Sep 17 00:00:00 2001\n"; buf.String() != want {
+		t.Errorf("Repositories.StreamChangesRaw wrote %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,290 @@
+/*
+ResourceClient implements the PavedRoad resource conventions once for any
+resource type. Resources live under the client's BaseURL:
+
+Verbs to    Path                         Functions
+----------  ---------------------------  ---------
+POST        /{resource}/                 Create
+GET         /{resource}/key              Get
+GET         /{resource}/key/sub          GetRaw
+GET         /{resource}LIST/             List, ListAll
+GET         /{resource}LIST/?watch=true  Watch
+PUT         /{resource}/key              Replace
+DELETE      /{resource}/key              Delete
+PATCH       /{resource}/key              Edit, Patch
+
+Services such as TokensService wrap a ResourceClient for their own type.
+*/
+package prclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// ResourceMetadata identifies a PavedRoad resource
+type ResourceMetadata struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	UID       string `json:"uid"`
+}
+
+// ResourceClient handles communication with a single PavedRoad resource type
+// whose objects are decoded into T.
+type ResourceClient[T any] struct {
+	client   *Client
+	resource string          // resource name, e.g. prTokens
+	keyName  string          // name of the key reported in errors, e.g. UUID
+	keyOf    func(*T) string // key of an object, used for Since paging
+}
+
+// NewResourceClient returns a ResourceClient for resource, e.g. "prTokens".
+// keyName names the identifier used in the resource path and is only used
+// in error messages. keyOf returns the identifier of an object and is used
+// by ListAll to page with the Since parameter; it may be nil.
+func NewResourceClient[T any](client *Client, resource, keyName string, keyOf func(*T) string) *ResourceClient[T] {
+	return &ResourceClient[T]{client: client, resource: resource, keyName: keyName, keyOf: keyOf}
+}
+
+// path returns the relative URL of the object identified by key.
+func (r *ResourceClient[T]) path(key string) (string, error) {
+	if key == "" {
+		return "", fmt.Errorf("%s is required", r.keyName)
+	}
+	return fmt.Sprintf("%s/%v", r.resource, key), nil
+}
+
+// RequestOption customizes a request before ResourceClient sends it.
+type RequestOption func(*http.Request)
+
+// IfMatch makes the request conditional on the object still being at
+// version, using an If-Match header. It does nothing if version is empty.
+func IfMatch(version string) RequestOption {
+	return func(req *http.Request) {
+		if version != "" {
+			req.Header.Set("If-Match", version)
+		}
+	}
+}
+
+// call sends a request with body to u and decodes the response into a new T.
+func (r *ResourceClient[T]) call(ctx context.Context, method, u string, body interface{}, opts ...RequestOption) (*T, *Response, error) {
+	req, err := r.client.NewRequest(method, u, body)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	obj := new(T)
+	resp, err := r.client.Do(ctx, req, obj)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return obj, resp, nil
+}
+
+// Create a new object.
+// PavedRoad API endpoint /{resource}/
+func (r *ResourceClient[T]) Create(ctx context.Context, obj *T) (*T, *Response, error) {
+	return r.call(ctx, "POST", fmt.Sprintf("%s/", r.resource), obj)
+}
+
+// Get fetches the object identified by key.
+// PavedRoad API endpoint /{resource}/key
+func (r *ResourceClient[T]) Get(ctx context.Context, key string) (*T, *Response, error) {
+	u, err := r.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r.call(ctx, "GET", u, nil)
+}
+
+// Delete the object identified by key.
+// PavedRoad API endpoint /{resource}/key
+func (r *ResourceClient[T]) Delete(ctx context.Context, key string) (*Response, error) {
+	u, err := r.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := r.client.NewRequest("DELETE", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	return r.client.Do(ctx, req, nil)
+}
+
+// Edit the object identified by key with a PATCH request.
+// PavedRoad API endpoint /{resource}/key
+func (r *ResourceClient[T]) Edit(ctx context.Context, obj *T, key string, opts ...RequestOption) (*T, *Response, error) {
+	u, err := r.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r.call(ctx, "PATCH", u, obj, opts...)
+}
+
+// Replace the object identified by key with a PUT request.
+// PavedRoad API endpoint /{resource}/key
+func (r *ResourceClient[T]) Replace(ctx context.Context, obj *T, key string, opts ...RequestOption) (*T, *Response, error) {
+	u, err := r.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r.call(ctx, "PUT", u, obj, opts...)
+}
+
+// Patch applies a partial update, such as a MergePatch or a JSONPatch, to
+// the object identified by key with a PATCH request.
+// PavedRoad API endpoint /{resource}/key
+func (r *ResourceClient[T]) Patch(ctx context.Context, key string, patch PatchDocument, opts ...RequestOption) (*T, *Response, error) {
+	if patch == nil {
+		return nil, nil, errors.New("patch is required")
+	}
+	u, err := r.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	contentType := func(req *http.Request) {
+		req.Header.Set("Content-Type", patch.ContentType())
+	}
+	return r.call(ctx, "PATCH", u, patch, append([]RequestOption{contentType}, opts...)...)
+}
+
+// GetRaw writes the subresource of the object identified by key, e.g. its
+// "history", to w in the raw format given by opt instead of JSON.
+// PavedRoad API endpoint /{resource}/key/subresource
+func (r *ResourceClient[T]) GetRaw(ctx context.Context, key, subresource string, opt RawOptions, w io.Writer) (*Response, error) {
+	u, err := r.path(key)
+	if err != nil {
+		return nil, err
+	}
+	accept, err := opt.acceptHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := r.client.NewRequest("GET", u+"/"+subresource, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+
+	return r.client.Do(ctx, req, w)
+}
+
+// List lists one page of objects. opt is encoded as URL query parameters
+// and must be a struct whose fields may contain "url" tags.
+// PavedRoad API endpoint /{resource}LIST/
+func (r *ResourceClient[T]) List(ctx context.Context, opt interface{}) ([]*T, *Response, error) {
+	u, err := addOptions(fmt.Sprintf("%sLIST/", r.resource), opt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req, err := r.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var objs []*T
+	resp, err := r.client.Do(ctx, req, &objs)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return objs, resp, nil
+}
+
+// Pager is implemented by list options that ListAll can advance from one
+// page to the next, such as ResourceListOptions.
+type Pager interface {
+	// Advance moves the options to the page following a page of n objects
+	// whose last key was last. It reports false when there are no more
+	// pages.
+	Advance(resp *Response, n int, last string) bool
+}
+
+// advancePage implements Pager for options made of ListOptions and a Since
+// key. Pages are followed using Response.NextPage when the server sends Link
+// headers; otherwise a full page (PerPage objects) is followed by a request
+// for the objects after the last key seen.
+func advancePage(opt *ListOptions, since *string, resp *Response, n int, last string) bool {
+	switch {
+	case resp.NextPage != 0:
+		opt.Page = resp.NextPage
+	case opt.PerPage > 0 && n == opt.PerPage && last != "" && last != *since:
+		*since = last
+	default:
+		return false
+	}
+	return true
+}
+
+// ResourceListOptions specifies optional parameters to ResourceClient.List
+// and ResourceClient.ListAll for resources without their own list options.
+type ResourceListOptions struct {
+	// Key of the last object seen
+	Since string `url:"since,omitempty"`
+
+	ListOptions
+}
+
+// Advance implements Pager.
+func (o *ResourceListOptions) Advance(resp *Response, n int, last string) bool {
+	return advancePage(&o.ListOptions, &o.Since, resp, n, last)
+}
+
+// ListAll walks every page of objects starting at opt, which is advanced in
+// place, and returns them all. A nil opt lists from the first page with the
+// default ResourceListOptions. ctx is checked between pages.
+func (r *ResourceClient[T]) ListAll(ctx context.Context, opt Pager) ([]*T, error) {
+	if opt == nil {
+		opt = &ResourceListOptions{}
+	}
+	var all []*T
+	for {
+		objs, resp, err := r.List(ctx, opt)
+		if err != nil {
+			return all, err
+		}
+		all = append(all, objs...)
+
+		var last string
+		if len(objs) > 0 && r.keyOf != nil {
+			last = r.keyOf(objs[len(objs)-1])
+		}
+		if !opt.Advance(resp, len(objs), last) {
+			return all, nil
+		}
+
+		if err := ctx.Err(); err != nil {
+			return all, err
+		}
+	}
+}
+
+// listInNamespaces calls list with a view of c scoped to each namespace in
+// turn and returns the objects found, keyed by namespace. On error, the
+// objects listed so far are returned along with the error.
+func listInNamespaces[T any](ctx context.Context, c *Client, namespaces []string, list func(*Client) ([]*T, error)) (map[string][]*T, error) {
+	all := make(map[string][]*T, len(namespaces))
+	for _, ns := range namespaces {
+		objs, err := list(c.Namespace(ns))
+		if err != nil {
+			return all, fmt.Errorf("namespace %s: %w", ns, err)
+		}
+		all[ns] = objs
+
+		if err := ctx.Err(); err != nil {
+			return all, err
+		}
+	}
+	return all, nil
+}
@@ -0,0 +1,115 @@
+package prclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// widget is a minimal resource used to exercise ResourceClient
+type widget struct {
+	UID  string `json:"uid"`
+	Name string `json:"name"`
+}
+
+func newWidgetClient(c *Client) *ResourceClient[widget] {
+	return NewResourceClient(c, "prWidgets", "UID", func(w *widget) string {
+		return w.UID
+	})
+}
+
+func TestResourceClient_paths(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/prWidgets/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/prWidgets/":
+			testMethod(t, r, "POST")
+			testBody(t, r, `{"uid":"","name":"w"}`+"\n")
+		case "/prWidgets/1":
+		default:
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		fmt.Fprint(w, `{"uid":"1","name":"w"}`)
+	})
+
+	rc := newWidgetClient(client)
+	want := &widget{UID: "1", Name: "w"}
+	ctx := context.Background()
+
+	got, _, err := rc.Create(ctx, &widget{Name: "w"})
+	if err != nil || !cmp.Equal(got, want) {
+		t.Errorf("Create returned %+v, %v, want %+v", got, err, want)
+	}
+	for _, f := range []func(context.Context, *widget, string, ...RequestOption) (*widget, *Response, error){rc.Edit, rc.Replace} {
+		got, _, err = f(ctx, want, "1")
+		if err != nil || !cmp.Equal(got, want) {
+			t.Errorf("returned %+v, %v, want %+v", got, err, want)
+		}
+	}
+	got, _, err = rc.Get(ctx, "1")
+	if err != nil || !cmp.Equal(got, want) {
+		t.Errorf("Get returned %+v, %v, want %+v", got, err, want)
+	}
+	if _, err = rc.Delete(ctx, "1"); err != nil {
+		t.Errorf("Delete returned error: %v", err)
+	}
+}
+
+func TestResourceClient_keyRequired(t *testing.T) {
+	rc := newWidgetClient(NewClient(nil))
+
+	if _, _, err := rc.Get(context.Background(), ""); err == nil || err.Error() != "UID is required" {
+		t.Errorf("Get returned error %v, want UID is required", err)
+	}
+	if _, err := rc.Delete(context.Background(), ""); err == nil {
+		t.Error("Expected error to be returned.")
+	}
+}
+
+func TestResourceClient_ListAll(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/prWidgetsLIST/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		switch r.FormValue("since") {
+		case "":
+			fmt.Fprint(w, `[{"uid":"a"}]`)
+		case "a":
+			fmt.Fprint(w, `[]`)
+		default:
+			t.Errorf("unexpected since %q", r.FormValue("since"))
+		}
+	})
+
+	opt := &ResourceListOptions{ListOptions: ListOptions{PerPage: 1}}
+	got, err := newWidgetClient(client).ListAll(context.Background(), opt)
+	if err != nil {
+		t.Fatalf("ListAll returned error: %v", err)
+	}
+	if want := []*widget{{UID: "a"}}; !cmp.Equal(got, want) {
+		t.Errorf("ListAll returned %+v, want %+v", got, want)
+	}
+}
+
+func TestResourceClient_ListAll_nilOptions(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/prWidgetsLIST/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"uid":"a"}]`)
+	})
+
+	got, err := newWidgetClient(client).ListAll(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("ListAll returned error: %v", err)
+	}
+	if want := []*widget{{UID: "a"}}; !cmp.Equal(got, want) {
+		t.Errorf("ListAll returned %+v, want %+v", got, want)
+	}
+}
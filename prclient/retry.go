@@ -0,0 +1,192 @@
+package prclient
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy specifies when and how Client.Do repeats a request that
+// failed with a transient error.
+//
+// A request is retried when the connection failed or was reset, or when the
+// response status is one of StatusCodes, provided its method is one of
+// Methods and its body can be rewound. Requests created by NewRequest can
+// always be rewound.
+//
+// Attempts are spaced by an exponential backoff with jitter, starting at
+// MinBackoff and capped at MaxBackoff. A Retry-After header on the response
+// takes precedence over the backoff.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+
+	// MinBackoff is the delay before the first retry. It doubles for every
+	// following retry. Defaults to 500 milliseconds.
+	MinBackoff time.Duration
+
+	// MaxBackoff caps the delay between two attempts. Defaults to 30
+	// seconds.
+	MaxBackoff time.Duration
+
+	// StatusCodes lists the response status codes that are retried.
+	// Defaults to DefaultRetryStatusCodes if nil.
+	StatusCodes []int
+
+	// Methods lists the HTTP methods that are retried.
+	// Defaults to DefaultRetryMethods if nil.
+	Methods []string
+}
+
+var (
+	// DefaultRetryStatusCodes are the status codes retried by a RetryPolicy
+	// without StatusCodes.
+	DefaultRetryStatusCodes = []int{
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	}
+
+	// DefaultRetryMethods are the idempotent methods retried by a
+	// RetryPolicy without Methods.
+	DefaultRetryMethods = []string{"GET", "HEAD", "OPTIONS", "PUT", "DELETE"}
+)
+
+const (
+	defaultRetryMinBackoff = 500 * time.Millisecond
+	defaultRetryMaxBackoff = 30 * time.Second
+)
+
+// NewRetryPolicy returns a RetryPolicy making up to maxAttempts attempts
+// with the default backoff, status codes and methods.
+func NewRetryPolicy(maxAttempts int) *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: maxAttempts,
+		MinBackoff:  defaultRetryMinBackoff,
+		MaxBackoff:  defaultRetryMaxBackoff,
+	}
+}
+
+// shouldRetry reports whether attempt number attempt at req, which ended
+// with resp and err, should be repeated. p may be nil.
+func (p *RetryPolicy) shouldRetry(attempt int, req *http.Request, resp *Response, err error) bool {
+	if p == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return false
+	}
+
+	methods := p.Methods
+	if methods == nil {
+		methods = DefaultRetryMethods
+	}
+	if !containsString(methods, req.Method) {
+		return false
+	}
+
+	if resp == nil {
+		return isTransientError(err)
+	}
+
+	codes := p.StatusCodes
+	if codes == nil {
+		codes = DefaultRetryStatusCodes
+	}
+	for _, c := range codes {
+		if resp.StatusCode == c {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns how long to wait after attempt number attempt, which ended
+// with resp, before the next one. resp may be nil.
+func (p *RetryPolicy) delay(attempt int, resp *Response) time.Duration {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return d
+		}
+	}
+
+	d, max := p.MinBackoff, p.MaxBackoff
+	if d <= 0 {
+		d = defaultRetryMinBackoff
+	}
+	if max <= 0 {
+		max = defaultRetryMaxBackoff
+	}
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+
+	// Use "equal jitter" so concurrent clients do not retry in lock step
+	// while still waiting at least half of the backoff.
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// parseRetryAfter parses a Retry-After header holding either a number of
+// seconds or an HTTP date.
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			secs = 0
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+	return 0, false
+}
+
+// isTransientError reports whether err, returned by the underlying
+// http.Client, is worth retrying.
+func isTransientError(err error) bool {
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
+
+// rewindBody resets the body of req so it can be sent again.
+func rewindBody(req *http.Request) error {
+	if req.Body == nil || req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
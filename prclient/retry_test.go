@@ -0,0 +1,161 @@
+package prclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestDo_retryStatus(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond}
+
+	attempts := 0
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "PUT")
+		testBody(t, r, `{"A":"a"}`+"\n")
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `{"A":"b"}`)
+	})
+
+	req, _ := client.NewRequest("PUT", ".", struct{ A string }{"a"})
+	body := new(struct{ A string })
+	if _, err := client.Do(context.Background(), req, body); err != nil {
+		t.Fatalf("Do returned error: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("server saw %d attempts, want 3", attempts)
+	}
+	if body.A != "b" {
+		t.Errorf("Response body = %v, want b", body.A)
+	}
+}
+
+func TestDo_retryExhausted(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond}
+
+	attempts := 0
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	req, _ := client.NewRequest("GET", ".", nil)
+	resp, err := client.Do(context.Background(), req, nil)
+	if err == nil {
+		t.Fatal("Expected error to be returned.")
+	}
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected HTTP 502 error, got %d status code.", resp.StatusCode)
+	}
+	if attempts != 2 {
+		t.Errorf("server saw %d attempts, want 2", attempts)
+	}
+}
+
+func TestDo_noRetry(t *testing.T) {
+	tests := []struct {
+		desc   string
+		policy *RetryPolicy
+		method string
+		status int
+	}{
+		{"nil policy", nil, "GET", http.StatusServiceUnavailable},
+		{"method not retried", NewRetryPolicy(3), "POST", http.StatusServiceUnavailable},
+		{"status not retried", NewRetryPolicy(3), "GET", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		client, mux, _, teardown := setup()
+		client.RetryPolicy = tt.policy
+
+		attempts := 0
+		mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			w.WriteHeader(tt.status)
+		})
+
+		req, _ := client.NewRequest(tt.method, ".", nil)
+		if _, err := client.Do(context.Background(), req, nil); err == nil {
+			t.Errorf("%s: Expected error to be returned.", tt.desc)
+		}
+		if attempts != 1 {
+			t.Errorf("%s: server saw %d attempts, want 1", tt.desc, attempts)
+		}
+		teardown()
+	}
+}
+
+func TestDo_retryCanceled(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 5, MinBackoff: time.Hour}
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	req, _ := client.NewRequest("GET", ".", nil)
+	if _, err := client.Do(ctx, req, nil); err != context.DeadlineExceeded {
+		t.Errorf("Do returned error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestRetryPolicy_delay(t *testing.T) {
+	p := &RetryPolicy{MinBackoff: time.Second, MaxBackoff: 4 * time.Second}
+
+	for attempt, max := range []time.Duration{0, time.Second, 2 * time.Second, 4 * time.Second, 4 * time.Second} {
+		if attempt == 0 {
+			continue
+		}
+		if d := p.delay(attempt, nil); d < max/2 || d > max {
+			t.Errorf("delay(%d) = %v, want between %v and %v", attempt, d, max/2, max)
+		}
+	}
+
+	resp := &Response{Response: &http.Response{Header: http.Header{"Retry-After": {"7"}}}}
+	if d := p.delay(1, resp); d != 7*time.Second {
+		t.Errorf("delay with Retry-After = %v, want 7s", d)
+	}
+}
+
+func TestRetryPolicy_delay_defaults(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 5, MinBackoff: time.Second}
+	for attempt, max := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second} {
+		if d := p.delay(attempt+1, nil); d < max/2 || d > max {
+			t.Errorf("delay(%d) = %v, want between %v and %v", attempt+1, d, max/2, max)
+		}
+	}
+	if d := p.delay(10, nil); d < defaultRetryMaxBackoff/2 || d > defaultRetryMaxBackoff {
+		t.Errorf("delay(10) = %v, want capped at %v", d, defaultRetryMaxBackoff)
+	}
+
+	p = &RetryPolicy{MaxAttempts: 5}
+	if d := p.delay(1, nil); d < defaultRetryMinBackoff/2 || d > defaultRetryMinBackoff {
+		t.Errorf("delay(1) without MinBackoff = %v, want between %v and %v", d, defaultRetryMinBackoff/2, defaultRetryMinBackoff)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if _, ok := parseRetryAfter(""); ok {
+		t.Error("parseRetryAfter(\"\") reported ok")
+	}
+	if _, ok := parseRetryAfter("soon"); ok {
+		t.Error("parseRetryAfter(\"soon\") reported ok")
+	}
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if d, ok := parseRetryAfter(date); !ok || d <= 0 || d > time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %v, %v", date, d, ok)
+	}
+}
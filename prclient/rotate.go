@@ -0,0 +1,119 @@
+package prclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// rotationRollbackTimeout bounds the deletion of the new token when a
+// TokensService.Rotate fails.
+const rotationRollbackTimeout = 10 * time.Second
+
+// TokenValidator checks that the secret of a token works, e.g. by calling
+// the site at its EndPoint with it.
+type TokenValidator interface {
+	Validate(ctx context.Context, token *Token) error
+}
+
+// TokenValidatorFunc is an adapter to use an ordinary function as a
+// TokenValidator.
+type TokenValidatorFunc func(ctx context.Context, token *Token) error
+
+// Validate calls f(ctx, token).
+func (f TokenValidatorFunc) Validate(ctx context.Context, token *Token) error {
+	return f(ctx, token)
+}
+
+// TokenRotation is the result of a successful TokensService.Rotate.
+type TokenRotation struct {
+	Old *Token // the replaced token, now inactive
+	New *Token // the new active token, whose RotatedFrom is the UID of Old
+}
+
+// RotationError reports a TokensService.Rotate that failed at Step.
+// Changes made before the failure have been undone, unless RollbackErr is
+// set, in which case the new token may have to be deleted by hand.
+type RotationError struct {
+	UID         string // UID of the token being rotated
+	Step        string // create, validate, activate or deactivate
+	Err         error  // cause of the failure
+	RollbackErr error  // error returned while undoing the rotation, if any
+	NewUID      string // UID of the new token, if it was created
+}
+
+func (e *RotationError) Error() string {
+	msg := fmt.Sprintf("rotating token %s: %s failed: %v", e.UID, e.Step, e.Err)
+	if e.RollbackErr != nil {
+		msg += fmt.Sprintf("; rollback of token %s failed: %v", e.NewUID, e.RollbackErr)
+	}
+	return msg
+}
+
+func (e *RotationError) Unwrap() error {
+	return e.Err
+}
+
+// Rotate replaces the secret of the token uid by newSecret.
+//
+// The old token, which must be active, is kept for audit: a new inactive
+// token holding newSecret is created, checked with validator, and
+// activated; the old token is deactivated last. Only the active field is
+// patched, so concurrent changes to the tokens are kept. If a step fails,
+// the new token is deleted and a *RotationError is returned. validator may
+// be nil to skip the check.
+func (s *TokensService) Rotate(ctx context.Context, uid, newSecret string, validator TokenValidator) (*TokenRotation, error) {
+	if newSecret == "" {
+		return nil, errors.New("newSecret is required")
+	}
+	old, _, err := s.Get(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	if !old.Active {
+		return nil, fmt.Errorf("rotating token %s: token is not active", uid)
+	}
+
+	next := *old
+	next.Metadata.UID = ""
+	next.Metadata.Token = newSecret
+	next.Metadata.RotatedFrom = uid
+	next.Created, next.Updated = nil, nil
+	next.Active = false
+
+	created, _, err := s.Create(ctx, next)
+	if err != nil {
+		return nil, &RotationError{UID: uid, Step: "create", Err: err}
+	}
+	newUID := created.Metadata.UID
+
+	fail := func(step string, err error) (*TokenRotation, error) {
+		rerr := &RotationError{UID: uid, Step: step, Err: err, NewUID: newUID}
+		// Roll back even if the rotation failed because ctx is done.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), rotationRollbackTimeout)
+		defer cancel()
+		if _, err := s.Delete(ctx, newUID); err != nil {
+			rerr.RollbackErr = err
+		}
+		return nil, rerr
+	}
+
+	if validator != nil {
+		if err := validator.Validate(ctx, created); err != nil {
+			return fail("validate", err)
+		}
+	}
+
+	activated, _, err := s.Patch(ctx, newUID, MergePatch{"active": true})
+	if err != nil {
+		return fail("activate", err)
+	}
+
+	deactivated, _, err := s.Patch(ctx, uid, MergePatch{"active": false})
+	if err != nil {
+		return fail("deactivate", err)
+	}
+
+	return &TokenRotation{Old: deactivated, New: activated}, nil
+}
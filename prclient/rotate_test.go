@@ -0,0 +1,155 @@
+package prclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// rotationServer serves prTokens from tokens, assigning UID "2" to the
+// created token and failing the patches of the token failEdit.
+func rotationServer(mux *http.ServeMux, tokens map[string]*Token, failEdit string) {
+	mux.HandleFunc("/"+tokenResource+"/", func(w http.ResponseWriter, r *http.Request) {
+		uid := strings.TrimPrefix(r.URL.Path, "/"+tokenResource+"/")
+
+		switch r.Method {
+		case "GET":
+			json.NewEncoder(w).Encode(tokens[uid])
+		case "POST":
+			v := new(Token)
+			json.NewDecoder(r.Body).Decode(v)
+			v.Metadata.UID = "2"
+			tokens["2"] = v
+			json.NewEncoder(w).Encode(v)
+		case "PATCH":
+			if uid == failEdit {
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			// Merge the patch into a copy of the stored token.
+			v := *tokens[uid]
+			json.NewDecoder(r.Body).Decode(&v)
+			tokens[uid] = &v
+			json.NewEncoder(w).Encode(&v)
+		case "PUT":
+			v := new(Token)
+			json.NewDecoder(r.Body).Decode(v)
+			tokens[uid] = v
+			json.NewEncoder(w).Encode(v)
+		case "DELETE":
+			delete(tokens, uid)
+		}
+	})
+}
+
+func TestTokensService_Rotate(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	tokens := map[string]*Token{"1": {Metadata: Metadata{UID: "1", Site: "github", Token: "old"}, Active: true}}
+	rotationServer(mux, tokens, "")
+
+	var validated string
+	validator := TokenValidatorFunc(func(ctx context.Context, token *Token) error {
+		validated = token.Metadata.Token
+		if token.Active {
+			t.Error("validated token is already active")
+		}
+		return nil
+	})
+
+	rot, err := client.Token.Rotate(context.Background(), "1", "new", validator)
+	if err != nil {
+		t.Fatalf("Tokens.Rotate returned error: %v", err)
+	}
+	if validated != "new" {
+		t.Errorf("validator called with secret %q, want %q", validated, "new")
+	}
+	if rot.Old.Active || rot.Old.Metadata.Token != "old" {
+		t.Errorf("Tokens.Rotate returned old token %+v", rot.Old)
+	}
+	if !rot.New.Active || rot.New.Metadata.Token != "new" || rot.New.Metadata.RotatedFrom != "1" || rot.New.Metadata.Site != "github" {
+		t.Errorf("Tokens.Rotate returned new token %+v", rot.New)
+	}
+	if tokens["1"].Active || !tokens["2"].Active {
+		t.Errorf("stored tokens are %v and %v", tokens["1"], tokens["2"])
+	}
+}
+
+func TestTokensService_Rotate_rollback(t *testing.T) {
+	tests := []struct {
+		step      string
+		validator TokenValidator
+		failEdit  string
+	}{
+		{"validate", TokenValidatorFunc(func(context.Context, *Token) error { return errors.New("bad credentials") }), ""},
+		{"activate", nil, "2"},
+		{"deactivate", nil, "1"},
+	}
+	for _, tt := range tests {
+		client, mux, _, teardown := setup()
+
+		tokens := map[string]*Token{"1": {Metadata: Metadata{UID: "1", Token: "old"}, Active: true}}
+		rotationServer(mux, tokens, tt.failEdit)
+
+		_, err := client.Token.Rotate(context.Background(), "1", "new", tt.validator)
+		var rerr *RotationError
+		if !errors.As(err, &rerr) || rerr.Step != tt.step || rerr.NewUID != "2" || rerr.RollbackErr != nil {
+			t.Errorf("Tokens.Rotate returned error %v, want a rolled back %s failure", err, tt.step)
+		}
+		if _, ok := tokens["2"]; ok {
+			t.Errorf("%s: new token was not deleted", tt.step)
+		}
+		if !tokens["1"].Active {
+			t.Errorf("%s: old token was deactivated", tt.step)
+		}
+		teardown()
+	}
+}
+
+func TestTokensService_Rotate_rollbackCanceled(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	tokens := map[string]*Token{"1": {Metadata: Metadata{UID: "1", Token: "old"}, Active: true}}
+	rotationServer(mux, tokens, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	validator := TokenValidatorFunc(func(ctx context.Context, token *Token) error {
+		cancel()
+		return ctx.Err()
+	})
+
+	_, err := client.Token.Rotate(ctx, "1", "new", validator)
+	var rerr *RotationError
+	if !errors.As(err, &rerr) || rerr.Step != "validate" || rerr.RollbackErr != nil {
+		t.Errorf("Tokens.Rotate returned error %v, want a rolled back validate failure", err)
+	}
+	if _, ok := tokens["2"]; ok {
+		t.Error("new token was not deleted after ctx was canceled")
+	}
+}
+
+func TestTokensService_Rotate_inactive(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	tokens := map[string]*Token{"1": {Metadata: Metadata{UID: "1", Token: "old"}}}
+	rotationServer(mux, tokens, "")
+
+	if _, err := client.Token.Rotate(context.Background(), "1", "new", nil); err == nil {
+		t.Error("Expected error to be returned for an inactive token.")
+	}
+	if _, ok := tokens["2"]; ok {
+		t.Error("Tokens.Rotate created a token for an inactive token")
+	}
+}
+
+func TestTokensService_Rotate_emptySecret(t *testing.T) {
+	if _, err := NewClient(nil).Token.Rotate(context.Background(), "1", "", nil); err == nil {
+		t.Error("Expected error to be returned for an empty secret.")
+	}
+}
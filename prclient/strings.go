@@ -5,23 +5,49 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"strings"
 )
 
 var timestampType = reflect.TypeOf(Timestamp{})
 
+// redacted replaces the value of secret fields in the output of Stringify.
+const redacted = "REDACTED"
+
 // Stringify attempts to create a reasonable string representation of types.
 // It does things like resolve pointers to their values
 // and omits struct fields with nil values.
+//
+// Struct fields tagged pr:"secret", such as Metadata.Token, are printed as
+// "REDACTED" unless they are empty, so values can be logged safely.
 func Stringify(message interface{}) string {
 	var buf bytes.Buffer
 	v := reflect.ValueOf(message)
-	stringifyValue(&buf, v)
+	stringifyValue(&buf, v, true)
+	return buf.String()
+}
+
+// StringifyUnredacted is like Stringify but reveals the fields tagged
+// pr:"secret". Its output must not be logged.
+func StringifyUnredacted(message interface{}) string {
+	var buf bytes.Buffer
+	v := reflect.ValueOf(message)
+	stringifyValue(&buf, v, false)
 	return buf.String()
 }
 
+// isSecret reports whether field f is tagged pr:"secret".
+func isSecret(f reflect.StructField) bool {
+	for _, opt := range strings.Split(f.Tag.Get("pr"), ",") {
+		if opt == "secret" {
+			return true
+		}
+	}
+	return false
+}
+
 // stringifyValue was heavily inspired by the goprotobuf library.
 
-func stringifyValue(w io.Writer, val reflect.Value) {
+func stringifyValue(w io.Writer, val reflect.Value, redact bool) {
 	if val.Kind() == reflect.Ptr && val.IsNil() {
 		w.Write([]byte("<nil>"))
 		return
@@ -39,7 +65,7 @@ func stringifyValue(w io.Writer, val reflect.Value) {
 				w.Write([]byte{' '})
 			}
 
-			stringifyValue(w, v.Index(i))
+			stringifyValue(w, v.Index(i), redact)
 		}
 
 		w.Write([]byte{']'})
@@ -75,7 +101,11 @@ func stringifyValue(w io.Writer, val reflect.Value) {
 
 			w.Write([]byte(v.Type().Field(i).Name))
 			w.Write([]byte{':'})
-			stringifyValue(w, fv)
+			if redact && !fv.IsZero() && isSecret(v.Type().Field(i)) {
+				fmt.Fprintf(w, `"%s"`, redacted)
+				continue
+			}
+			stringifyValue(w, fv, redact)
 		}
 
 		w.Write([]byte{'}'})
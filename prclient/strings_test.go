@@ -0,0 +1,50 @@
+package prclient
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestStringify(t *testing.T) {
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{"foo", `"foo"`},
+		{[]string{"a", "b"}, `["a" "b"]`},
+		{struct{ A *int }{}, `{}`},
+		{Rate{Limit: 1}, `prclient.Rate{Limit:1, Remaining:0, Reset:prclient.Timestamp{0001-01-01 00:00:00 +0000 UTC}}`},
+	}
+	for _, tt := range tests {
+		if got := Stringify(tt.in); got != tt.want {
+			t.Errorf("Stringify(%#v) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestStringify_redactsSecrets(t *testing.T) {
+	token := &Token{Metadata: Metadata{UID: "1", Token: "s3cr3t"}}
+	mapper := UserIdMapper{Credential: "s3cr3t"}
+	transport := BasicAuthTransport{Username: "u", Password: "s3cr3t", OTP: "s3cr3t"}
+
+	for _, s := range []string{
+		token.String(),
+		fmt.Sprintf("%v", token),
+		fmt.Sprint(token.Metadata),
+		mapper.String(),
+		transport.String(),
+		fmt.Sprintf("%v", &transport),
+	} {
+		if strings.Contains(s, "s3cr3t") || !strings.Contains(s, `"REDACTED"`) {
+			t.Errorf("secret not redacted in %s", s)
+		}
+	}
+
+	if s := Stringify(Token{}); strings.Contains(s, "REDACTED") {
+		t.Errorf("empty secret redacted in %s", s)
+	}
+	if s := StringifyUnredacted(token); !strings.Contains(s, `Token:"s3cr3t"`) {
+		t.Errorf("StringifyUnredacted(%v) = %s, want the secret revealed", token, s)
+	}
+}
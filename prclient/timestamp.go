@@ -1,6 +1,7 @@
 package prclient
 
 import (
+	"math"
 	"strconv"
 	"time"
 )
@@ -15,19 +16,45 @@ func (t Timestamp) String() string {
 	return t.Time.String()
 }
 
+// MarshalJSON implements the json.Marshaler interface.
+// The time is a quoted string in RFC3339 format, with sub-second precision.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + t.Time.Format(time.RFC3339Nano) + `"`), nil
+}
+
 // UnmarshalJSON implements the json.Unmarshaler interface.
-// Time is expected in RFC3339 or Unix format.
+// Time is expected in RFC3339 or Unix format. Unix times may be fractional,
+// or given in milliseconds, and may be quoted. null and "" leave t unchanged.
 func (t *Timestamp) UnmarshalJSON(data []byte) (err error) {
 	str := string(data)
-	i, err := strconv.ParseInt(str, 10, 64)
-	if err == nil {
-		t.Time = time.Unix(i, 0)
-	} else {
-		t.Time, err = time.Parse(`"`+time.RFC3339+`"`, str)
+	if str == "null" || str == `""` {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(str); err == nil {
+		str = unquoted
+	}
+	if f, err := strconv.ParseFloat(str, 64); err == nil {
+		t.Time = unixTime(f)
+		return nil
 	}
+	t.Time, err = time.Parse(time.RFC3339, str)
 	return
 }
 
+// maxUnixSeconds is the largest epoch read as seconds rather than
+// milliseconds; it falls in the year 5138.
+const maxUnixSeconds = 1e11
+
+// unixTime converts an epoch in seconds, or milliseconds if larger than
+// maxUnixSeconds, to a time.
+func unixTime(f float64) time.Time {
+	if math.Abs(f) > maxUnixSeconds {
+		f /= 1000
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(math.Round(frac*1e9)))
+}
+
 // Equal reports whether t and u are equal based on time.Equal
 func (t Timestamp) Equal(u Timestamp) bool {
 	return t.Time.Equal(u.Time)
@@ -28,6 +28,8 @@ func TestTimestamp_Marshal(t *testing.T) {
 		equal   bool
 	}{
 		{"Reference", Timestamp{referenceTime}, referenceTimeStr, false, true},
+		{"ReferenceFractional", Timestamp{referenceTime.Add(time.Millisecond)}, `"2006-01-02T15:04:05.001Z"`, false, true},
+		{"ReferenceOffset", Timestamp{referenceTime.In(time.FixedZone("", -7*3600))}, `"2006-01-02T08:04:05-07:00"`, false, true},
 		{"Empty", Timestamp{}, emptyTimeStr, false, true},
 		{"Mismatch", Timestamp{}, referenceTimeStr, false, false},
 	}
@@ -57,6 +59,11 @@ func TestTimestamp_Unmarshal(t *testing.T) {
 		{"ReferenceFractional", referenceTimeStrFractional, Timestamp{referenceTime}, false, true},
 		{"Empty", emptyTimeStr, Timestamp{}, false, true},
 		{"UnixStart", `0`, Timestamp{unixOrigin}, false, true},
+		{"ReferenceUnixFractional", `1136214245.25`, Timestamp{referenceTime.Add(250 * time.Millisecond)}, false, true},
+		{"ReferenceUnixMillis", `1136214245250`, Timestamp{referenceTime.Add(250 * time.Millisecond)}, false, true},
+		{"ReferenceUnixQuoted", `"1136214245"`, Timestamp{referenceTime}, false, true},
+		{"Null", `null`, Timestamp{}, false, true},
+		{"EmptyString", `""`, Timestamp{}, false, true},
 		{"Mismatch", referenceTimeStr, Timestamp{}, false, false},
 		{"MismatchUnix", `0`, Timestamp{}, false, false},
 		{"Invalid", `"asdf"`, Timestamp{referenceTime}, true, false},
@@ -182,3 +189,13 @@ func TestWrappedTimstamp_MarshalReflexivity(t *testing.T) {
 		}
 	}
 }
+
+func TestTimestamp_MarshalPointerOmitempty(t *testing.T) {
+	v := struct {
+		Created *Timestamp `json:"created,omitempty"`
+	}{}
+	testJSONMarshal(t, &v, `{}`)
+
+	v.Created = &Timestamp{referenceTime}
+	testJSONMarshal(t, &v, `{"created":`+referenceTimeStr+`}`)
+}
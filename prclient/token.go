@@ -7,17 +7,22 @@ Verbs to Functions
 ------   ---------
 POST     Create
 GET      Get
-GET/     List resources
+GET/     List resources, Watch
 PUT      Replace
 DELETE   Delete
-PATCH    Edit
+PATCH    Edit, Patch
 */
 package prclient
 
 import (
+	"bytes"
 	"context"
-	"errors"
+	"crypto/rand"
+	"encoding/json"
 	"fmt"
+	"io"
+	"strings"
+	"time"
 )
 
 // TokensService handles communication with the token related
@@ -26,12 +31,19 @@ type TokensService service
 
 // Token data structure for token storage
 type Token struct {
-	APIVersion string   `json:"apiVersion"`
-	Kind       string   `json:"kind"`
-	Metadata   Metadata `json:"metadata"`
-	Created    string   `json:"created,ignoreempty"`
-	Updated    string   `json:"updated"`
-	Active     bool     `json:"active"`
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   Metadata   `json:"metadata"`
+	Created    *Timestamp `json:"created,omitempty"`
+	Updated    *Timestamp `json:"updated,omitempty"`
+	Active     bool       `json:"active"`
+
+	// ExpiresAt is when the credential lapses on the third party site,
+	// nil if it does not expire.
+	ExpiresAt *Timestamp `json:"expiresAt,omitempty"`
+
+	// LastUsed is when the credential was last read to call the site.
+	LastUsed *Timestamp `json:"lastUsed,omitempty"`
 }
 
 // Metadata stored for a token
@@ -41,150 +53,342 @@ type Metadata struct {
 	UID       string   `json:"uid"`
 	Site      string   `json:"site"`
 	EndPoint  string   `json:"endPoint"`
-	Token     string   `json:"token"`
+	Token     string   `json:"token" pr:"secret"` // encrypted by the client when Client.KeyProvider is set
 	Scope     []string `json:"scope"`
+
+	// RotatedFrom is the UID of the token this one replaced, see
+	// TokensService.Rotate.
+	RotatedFrom string `json:"rotatedFrom,omitempty"`
 }
 
 func (u Token) String() string {
 	return Stringify(u)
 }
 
+func (m Metadata) String() string {
+	return Stringify(m)
+}
+
+// ExpiresBefore reports whether t has an expiry time before deadline.
+func (t *Token) ExpiresBefore(deadline time.Time) bool {
+	return t.ExpiresAt != nil && t.ExpiresAt.Before(deadline)
+}
+
+// resource returns the generic client for prTokens resources.
+func (s *TokensService) resource() *ResourceClient[Token] {
+	return NewResourceClient(s.client, tokenResource, "UUID", func(t *Token) string {
+		return t.Metadata.UID
+	})
+}
+
 // Create a token
+// When Client.KeyProvider is set, a token with a secret but no UID is
+// given a random one, as its encrypted secret is bound to its UID.
 // PavedRoad API endpoint /prTokens/
 func (s *TokensService) Create(ctx context.Context, newToken Token) (*Token, *Response, error) {
-	var u = fmt.Sprintf("%s/", tokenResource)
-
-	req, err := s.client.NewRequest("POST", u, newToken)
+	t, err := s.encrypt(ctx, &newToken, newToken.Metadata.UID)
 	if err != nil {
 		return nil, nil, err
 	}
+	return s.decrypted(ctx)(s.resource().Create(ctx, t))
+}
+
+// Get fetches a token using based on a UUID.
+// PavedRoad API endpoint /prTokens/uuid.
+func (s *TokensService) Get(ctx context.Context, uuid string) (*Token, *Response, error) {
+	return s.decrypted(ctx)(s.resource().Get(ctx, uuid))
+}
+
+// Delete a token using a UUID.
+// PavedRoad API endpoint /prTokens/uuid.
+func (s *TokensService) Delete(ctx context.Context, uuid string) (*Response, error) {
+	return s.resource().Delete(ctx, uuid)
+}
 
-  rToken := &Token{}
-	resp, err := s.client.Do(ctx, req, rToken)
+// Edit a token.
+// The whole token is sent, so zero values overwrite the stored ones; use
+// Patch to change only some fields.
+// PavedRoad API docs: https://developer.pavedroad.io/v1/token/#update-token
+func (s *TokensService) Edit(ctx context.Context, token *Token, uuid string) (*Token, *Response, error) {
+	t, err := s.encrypt(ctx, token, uuid)
 	if err != nil {
-		return nil, resp, err
+		return nil, nil, err
 	}
-
-	return rToken, resp, nil
+	return s.decrypted(ctx)(s.resource().Edit(ctx, t, uuid))
 }
 
-// Get fetches a token using based on a UUID.
+// Patch applies a MergePatch or a JSONPatch to a token, e.g. one computed
+// with CreateMergePatch from the token as read and as modified.
 // PavedRoad API endpoint /prTokens/uuid.
-func (s *TokensService) Get(ctx context.Context, uuid string) (*Token, *Response, error) {
-	var u string
-	if uuid != "" {
-		u = fmt.Sprintf("%s/%v", tokenResource, uuid)
-	} else {
-		return nil, nil, errors.New("UUID is required")
+func (s *TokensService) Patch(ctx context.Context, uuid string, patch PatchDocument) (*Token, *Response, error) {
+	patch, err := s.encryptPatch(ctx, uuid, patch)
+	if err != nil {
+		return nil, nil, err
 	}
-	req, err := s.client.NewRequest("GET", u, nil)
+	return s.decrypted(ctx)(s.resource().Patch(ctx, uuid, patch))
+}
+
+// Replace a token.
+// PavedRoad API docs: https://developer.pavedroad.io/v1/token/#replace-token
+func (s *TokensService) Replace(ctx context.Context, token *Token, uuid string) (*Token, *Response, error) {
+	t, err := s.encrypt(ctx, token, uuid)
 	if err != nil {
 		return nil, nil, err
 	}
+	return s.decrypted(ctx)(s.resource().Replace(ctx, t, uuid))
+}
 
-	uResp := new(Token)
-	resp, err := s.client.Do(ctx, req, uResp)
+// GetHistoryRaw returns the history of a token in the raw format
+// given by opt, e.g. a diff between its versions.
+// PavedRoad API endpoint /prTokens/uuid/history.
+func (s *TokensService) GetHistoryRaw(ctx context.Context, uuid string, opt RawOptions) (string, *Response, error) {
+	var buf bytes.Buffer
+	resp, err := s.StreamHistoryRaw(ctx, uuid, opt, &buf)
 	if err != nil {
-		return nil, resp, err
+		return "", resp, err
 	}
+	return buf.String(), resp, nil
+}
 
-	return uResp, resp, nil
+// StreamHistoryRaw writes the history of a token to w in the raw
+// format given by opt, without buffering it.
+// PavedRoad API endpoint /prTokens/uuid/history.
+func (s *TokensService) StreamHistoryRaw(ctx context.Context, uuid string, opt RawOptions, w io.Writer) (*Response, error) {
+	return s.resource().GetRaw(ctx, uuid, "history", opt, w)
 }
 
-// Delete a token using a UUID.
-// PavedRoad API endpoint /prTokens/uuid.
-func (s *TokensService) Delete(ctx context.Context, uuid string) (*Response, error) {
-	var u string
+// TokenListOptions specifies optional parameters to the TokensService.List
+// and TokensService.ListAll methods.
+type TokenListOptions struct {
+	// UID of the last token seen
+	Since string `url:"since,omitempty"`
 
-	if uuid != "" {
-		u = fmt.Sprintf("%s/%v", tokenResource, uuid)
-	} else {
-		return nil, errors.New("UUID is required")
-	}
-	req, err := s.client.NewRequest("DELETE", u, nil)
-	if err != nil {
-		return nil, err
-	}
-	return s.client.Do(ctx, req, nil)
+	// ExpiringBefore filters the tokens expiring before this time.
+	ExpiringBefore time.Time `url:"expiringBefore,omitempty"`
+
+	// Note: when the server does not return Link headers, pagination is
+	// powered by the Since parameter and ListOptions.Page has no effect.
+	ListOptions
 }
 
-// Edit a token.
-// PavedRoad API docs: https://developer.pavedroad.io/v1/token/#update-token
-func (s *TokensService) Edit(ctx context.Context, token *Token, uuid string) (*Token, *Response, error) {
-	var u string
-	if uuid != "" {
-		u = fmt.Sprintf("%s/%v", tokenResource, uuid)
-	} else {
-		return nil, nil, errors.New("UUID is required")
-	}
+// Advance implements Pager.
+func (o *TokenListOptions) Advance(resp *Response, n int, last string) bool {
+	return advancePage(&o.ListOptions, &o.Since, resp, n, last)
+}
 
-	req, err := s.client.NewRequest("PATCH", u, token)
+// List lists one page of PavedRoad tokens.
+// PavedRoad API endpoint /prTokensLIST/
+func (s *TokensService) List(ctx context.Context, opt *TokenListOptions) ([]*Token, *Response, error) {
+	tokens, resp, err := s.resource().List(ctx, opt)
 	if err != nil {
-		return nil, nil, err
+		return tokens, resp, err
 	}
+	return tokens, resp, s.decrypt(ctx, tokens...)
+}
 
-	tResp := new(Token)
-	resp, err := s.client.Do(ctx, req, tResp)
+// ListAll walks every page of PavedRoad tokens starting at opt and returns
+// them all. See ResourceClient.ListAll.
+func (s *TokensService) ListAll(ctx context.Context, opt *TokenListOptions) ([]*Token, error) {
+	var o TokenListOptions
+	if opt != nil {
+		o = *opt
+	}
+	tokens, err := s.resource().ListAll(ctx, &o)
 	if err != nil {
-		return nil, resp, err
+		return tokens, err
 	}
+	return tokens, s.decrypt(ctx, tokens...)
+}
 
-	return tResp, resp, nil
+// Watch streams the changes made to the PavedRoad tokens, with their
+// secrets decrypted. See ResourceClient.Watch.
+// PavedRoad API endpoint /prTokensLIST/?watch=true
+func (s *TokensService) Watch(ctx context.Context, opt *WatchOptions) (<-chan WatchEvent[Token], error) {
+	return s.resource().watch(ctx, opt, func(t *Token) error {
+		return s.decrypt(ctx, t)
+	})
 }
 
-// Replace a token.
-// PavedRoad API docs: https://developer.pavedroad.io/v1/token/#replace-token
-func (s *TokensService) Replace(ctx context.Context, token *Token, uuid string) (*Token, *Response, error) {
-	var u string
-	if uuid != "" {
-		u = fmt.Sprintf("%s/%v", tokenResource, uuid)
-	} else {
-		return nil, nil, errors.New("UUID is required")
+// ExpiringWithin returns every token, matching opt, that expires within
+// window from now, including those already expired, so their owners can be
+// warned before the credentials lapse. Tokens without expiry are skipped.
+func (s *TokensService) ExpiringWithin(ctx context.Context, window time.Duration, opt *TokenListOptions) ([]*Token, error) {
+	var o TokenListOptions
+	if opt != nil {
+		o = *opt
 	}
+	o.ExpiringBefore = time.Now().Add(window).UTC().Truncate(time.Second)
 
-	req, err := s.client.NewRequest("PUT", u, token)
+	tokens, err := s.ListAll(ctx, &o)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
-	tResp := new(Token)
-	resp, err := s.client.Do(ctx, req, tResp)
-	if err != nil {
-		return nil, resp, err
+	// The filter is applied again here since the server may ignore it.
+	var expiring []*Token
+	for _, t := range tokens {
+		if t.ExpiresBefore(o.ExpiringBefore) {
+			expiring = append(expiring, t)
+		}
 	}
+	return expiring, nil
+}
 
-	return tResp, resp, nil
+// ListInNamespaces lists every token of each namespace in namespaces,
+// using the connection pool of the client, and returns them keyed by
+// namespace.
+func (s *TokensService) ListInNamespaces(ctx context.Context, namespaces []string, opt *TokenListOptions) (map[string][]*Token, error) {
+	return listInNamespaces(ctx, s.client, namespaces, func(c *Client) ([]*Token, error) {
+		return c.Token.ListAll(ctx, opt)
+	})
 }
 
+// encrypt returns a copy of t whose secret is encrypted with the
+// KeyProvider of the client for the token uuid. If uuid is empty, t is
+// given a new random UID first. t is returned unchanged if there is no
+// KeyProvider or its secret is empty or already encrypted.
+func (s *TokensService) encrypt(ctx context.Context, t *Token, uuid string) (*Token, error) {
+	kp := s.client.KeyProvider
+	if kp == nil || t == nil || t.Metadata.Token == "" || strings.HasPrefix(t.Metadata.Token, encryptedPrefix) {
+		return t, nil
+	}
+	c := *t
+	if uuid == "" {
+		var err error
+		if uuid, err = newUID(); err != nil {
+			return nil, err
+		}
+		c.Metadata.UID = uuid
+	}
+	secret, err := encryptSecret(ctx, kp, t.Metadata.Token, secretAAD(uuid))
+	if err != nil {
+		return nil, err
+	}
+	c.Metadata.Token = secret
+	return &c, nil
+}
 
-// TokenListOptions specifies optional parameters to the TokensService.ListAll
-// method.
-type TokenListOptions struct {
-       // ID of the last token seen
-       Since int64 `url:"since,omitempty"`
+// secretAAD returns the additional data binding an encrypted secret to the
+// token uuid, so that it cannot be copied into another token, be it one
+// with the same name, a rotated one or one of another namespace.
+func secretAAD(uuid string) []byte {
+	return []byte(tokenResource + "/" + uuid)
+}
 
-       // Note: Pagination is powered exclusively by the Since parameter,
-       // ListOptions.Page has no effect.
-       // ListOptions.PerPage controls an undocumented PavedRoad API parameter.
-       ListOptions
+// newUID returns a random (version 4) UUID.
+func newUID() (string, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
 }
 
-// ListAll lists all PavedRoad token.
-func (s *TokensService) List(ctx context.Context, opt *TokenListOptions) (*Response, error) {
-	var u = fmt.Sprintf("%s/", tokenResourceList)
+// decrypt decrypts in place the secret of each token encrypted by the
+// client. Plaintext secrets are left untouched.
+func (s *TokensService) decrypt(ctx context.Context, tokens ...*Token) error {
+	kp := s.client.KeyProvider
+	if kp == nil {
+		return nil
+	}
+	for _, t := range tokens {
+		if t == nil {
+			continue
+		}
+		secret, err := decryptSecret(ctx, kp, t.Metadata.Token, secretAAD(t.Metadata.UID))
+		if err != nil {
+			return fmt.Errorf("decrypting token %s: %w", t.Metadata.UID, err)
+		}
+		t.Metadata.Token = secret
+	}
+	return nil
+}
 
-  // convert to our method of paging
+// decrypted returns a function decrypting the token returned by a
+// ResourceClient call, so it can wrap the call directly.
+func (s *TokensService) decrypted(ctx context.Context) func(*Token, *Response, error) (*Token, *Response, error) {
+	return func(t *Token, resp *Response, err error) (*Token, *Response, error) {
+		if err != nil {
+			return t, resp, err
+		}
+		if err := s.decrypt(ctx, t); err != nil {
+			return nil, resp, err
+		}
+		return t, resp, nil
+	}
+}
 
-	req, err := s.client.NewRequest("GET", u, nil)
+// encryptPatch returns a copy of patch in which the new values of
+// metadata.token are encrypted with the KeyProvider of the client for the
+// token uuid. The patch is normalized through JSON first, so the secret is
+// found whatever the Go types used to build it.
+func (s *TokensService) encryptPatch(ctx context.Context, uuid string, patch PatchDocument) (PatchDocument, error) {
+	kp := s.client.KeyProvider
+	if kp == nil || patch == nil {
+		return patch, nil
+	}
+	b, err := json.Marshal(patch)
 	if err != nil {
 		return nil, err
 	}
 
-	var token []*Token
-	resp, err := s.client.Do(ctx, req, &token)
-	if err != nil {
-		return resp, err
+	encrypt := func(v interface{}) (interface{}, error) {
+		secret, ok := v.(string)
+		if !ok || secret == "" || strings.HasPrefix(secret, encryptedPrefix) {
+			return v, nil
+		}
+		return encryptSecret(ctx, kp, secret, secretAAD(uuid))
+	}
+	encryptMetadata := func(v interface{}) error {
+		md, _ := v.(map[string]interface{})
+		if secret, ok := md["token"]; ok {
+			var err error
+			md["token"], err = encrypt(secret)
+			return err
+		}
+		return nil
 	}
 
-	return resp, nil
+	switch patch.(type) {
+	case MergePatch:
+		var p MergePatch
+		if err := json.Unmarshal(b, &p); err != nil {
+			return nil, err
+		}
+		if err := encryptMetadata(p["metadata"]); err != nil {
+			return nil, err
+		}
+		return p, nil
+
+	case JSONPatch:
+		var p JSONPatch
+		if err := json.Unmarshal(b, &p); err != nil {
+			return nil, err
+		}
+		for i := range p {
+			op := &p[i]
+			if op.Path != "/metadata" && op.Path != "/metadata/token" {
+				continue
+			}
+			switch op.Op {
+			case "add", "replace":
+			case "move", "copy":
+				return nil, fmt.Errorf("cannot encrypt the token secret of a patch that will %s to %s", op.Op, op.Path)
+			default:
+				continue
+			}
+			if op.Path == "/metadata" {
+				err = encryptMetadata(op.Value)
+			} else {
+				op.Value, err = encrypt(op.Value)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		return p, nil
+	}
+	return nil, fmt.Errorf("cannot encrypt the token secret of a %T", patch)
 }
@@ -6,20 +6,23 @@ import (
 	"fmt"
 	"github.com/google/go-cmp/cmp"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	_ "reflect"
 	"testing"
+	"time"
 )
 
 // blankToken is an initialized object with defaults
-var blankTokenJSON = `{"apiVersion":"1","kind":"","metadata":{"name":"","namespace":"","uid":"","site":"","endPoint":"","token":"","scope":null},"created":"","updated":"","active":false}`
+var blankTokenJSON = `{"apiVersion":"1","kind":"","metadata":{"name":"","namespace":"","uid":"","site":"","endPoint":"","token":"","scope":null},"active":false}`
 
-var blankTokenObject = `{APIVersion:"", Kind:"", Metadata:prclient.Metadata{Name:"", Namespace:"", UID:"", Site:"", EndPoint:"", Token:""}, Created:"", Updated:"", Active:false}`
+var blankTokenObject = `{APIVersion:"", Kind:"", Metadata:prclient.Metadata{Name:"", Namespace:"", UID:"", Site:"", EndPoint:"", Token:""}, Active:false}`
 
 // fakeToken is created by NewToken which provides sample data
-var fakeTokenObject = `{APIVersion:"core.pavedroad.io/v1alpha1", Kind:"PrToken", Metadata:prclient.Metadata{Name:"testoken", Namespace:"", UID:"", Site:"github", EndPoint:"https://api.github.com", Token:"#####################", Scope:["user" "repo"]}, Created:"", Updated:"", Active:true}`
-var fakeTokenJSON = `{"apiVersion":"core.pavedroad.io/v1alpha1", "kind":"prToken", "metadata":{"name":"testoken", "namespace":"", "uid":"", "site":"github", "endPoint":"https://api.github.com", "token":"#####################", "scope":["user", "repo"]}, "created":"", "updated":"", "active":true}`
+var fakeTokenObject = `{APIVersion:"core.pavedroad.io/v1alpha1", Kind:"PrToken", Metadata:prclient.Metadata{Name:"testoken", Namespace:"", UID:"", Site:"github", EndPoint:"https://api.github.com", Token:"#####################", Scope:["user" "repo"]}, Active:true}`
+var fakeTokenJSON = `{"apiVersion":"core.pavedroad.io/v1alpha1", "kind":"prToken", "metadata":{"name":"testoken", "namespace":"", "uid":"", "site":"github", "endPoint":"https://api.github.com", "token":"#####################", "scope":["user", "repo"]}, "active":true}`
 
-//create a token, set default values
+// create a token, set default values
 func NewToken() (t *Token) {
 	Token := Token{}
 
@@ -32,8 +35,6 @@ func NewToken() (t *Token) {
 	Token.Metadata.Token = "#####################"
 	Token.Metadata.Scope = append(Token.Metadata.Scope, "user")
 	Token.Metadata.Scope = append(Token.Metadata.Scope, "repo")
-	Token.Created = ""
-	Token.Updated = ""
 	Token.Active = true
 
 	return &Token
@@ -76,34 +77,34 @@ func TestTokensService_Delete_specifiedToken(t *testing.T) {
 	client, mux, _, teardown := setup()
 	defer teardown()
 
-  mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-    testMethod(t, r, "DELETE")
-  })
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "DELETE")
+	})
 
-  _, err := client.Token.Delete(context.Background(), "1")
+	_, err := client.Token.Delete(context.Background(), "1")
 
-  if err != nil {
-    t.Errorf("UserIdMapper delete returned error: %v", err)
-  }
+	if err != nil {
+		t.Errorf("UserIdMapper delete returned error: %v", err)
+	}
 }
 
 func TestTokenService_Replace(t *testing.T) {
-  client, mux, _, teardown := setup()
-  defer teardown()
+	client, mux, _, teardown := setup()
+	defer teardown()
 
-  input := &Token{APIVersion: "1"}
+	input := &Token{APIVersion: "1"}
 
-  mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-    v := &Token{APIVersion: "1"}
-    json.NewDecoder(r.Body).Decode(v)
-    testMethod(t, r, "PUT")
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		v := &Token{APIVersion: "1"}
+		json.NewDecoder(r.Body).Decode(v)
+		testMethod(t, r, "PUT")
 		fmt.Fprint(w, blankTokenJSON)
-  })
+	})
 
-  token, _, err := client.Token.Replace(context.Background(), input, "foo")
-  if err != nil {
-    t.Errorf("Token.Replace returned error: %v", err)
-  }
+	token, _, err := client.Token.Replace(context.Background(), input, "foo")
+	if err != nil {
+		t.Errorf("Token.Replace returned error: %v", err)
+	}
 
 	want := &Token{APIVersion: "1"}
 	if !cmp.Equal(token, want) {
@@ -114,18 +115,241 @@ func TestTokenService_Replace(t *testing.T) {
 func TestTokensService_List_Tokens(t *testing.T) {
 	client, mux, _, teardown := setup()
 	defer teardown()
-  var opt *TokenListOptions
+	var opt *TokenListOptions
 
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		testMethod(t, r, "GET")
-    fmt.Fprint(w, "")
+		fmt.Fprint(w, "")
 	})
 
-  //var u = fmt.Sprintf("%s/", tokenResourceList)
-  _, err := client.Token.List(context.Background(), opt )
+	tokens, _, err := client.Token.List(context.Background(), opt)
 	if err != nil {
 		t.Errorf("Tokens.List returned error: %v", err)
 	}
+	if len(tokens) != 0 {
+		t.Errorf("Tokens.List returned %+v, want none", tokens)
+	}
+}
+
+func TestTokensService_List_options(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+tokenResourceList+"/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		testFormValues(t, r, values{"since": "abc", "per_page": "2"})
+		fmt.Fprint(w, `[{"apiVersion":"1","metadata":{"uid":"abd"}}]`)
+	})
+
+	opt := &TokenListOptions{Since: "abc", ListOptions: ListOptions{PerPage: 2}}
+	tokens, _, err := client.Token.List(context.Background(), opt)
+	if err != nil {
+		t.Errorf("Tokens.List returned error: %v", err)
+	}
+
+	want := []*Token{{APIVersion: "1", Metadata: Metadata{UID: "abd"}}}
+	if !cmp.Equal(tokens, want) {
+		t.Errorf("Tokens.List returned %+v, want %+v", tokens, want)
+	}
+}
+
+func TestTokensService_ListAll_linkPages(t *testing.T) {
+	client, mux, serverURL, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+tokenResourceList+"/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		switch r.FormValue("page") {
+		case "":
+			w.Header().Set("Link", fmt.Sprintf(`<%s/?page=2>; rel="next"`, serverURL))
+			fmt.Fprint(w, `[{"metadata":{"uid":"1"}}]`)
+		case "2":
+			fmt.Fprint(w, `[{"metadata":{"uid":"2"}}]`)
+		default:
+			t.Errorf("unexpected page %q", r.FormValue("page"))
+		}
+	})
+
+	tokens, err := client.Token.ListAll(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Tokens.ListAll returned error: %v", err)
+	}
+
+	want := []*Token{{Metadata: Metadata{UID: "1"}}, {Metadata: Metadata{UID: "2"}}}
+	if !cmp.Equal(tokens, want) {
+		t.Errorf("Tokens.ListAll returned %+v, want %+v", tokens, want)
+	}
+}
+
+func TestTokensService_ListAll_since(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+tokenResourceList+"/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.FormValue("since") {
+		case "":
+			fmt.Fprint(w, `[{"metadata":{"uid":"1"}},{"metadata":{"uid":"2"}}]`)
+		case "2":
+			fmt.Fprint(w, `[{"metadata":{"uid":"3"}}]`)
+		default:
+			t.Errorf("unexpected since %q", r.FormValue("since"))
+		}
+	})
+
+	opt := &TokenListOptions{ListOptions: ListOptions{PerPage: 2}}
+	tokens, err := client.Token.ListAll(context.Background(), opt)
+	if err != nil {
+		t.Fatalf("Tokens.ListAll returned error: %v", err)
+	}
+	if len(tokens) != 3 {
+		t.Errorf("Tokens.ListAll returned %d tokens, want 3", len(tokens))
+	}
+}
+
+func TestTokensService_ListAll_canceled(t *testing.T) {
+	client, mux, serverURL, teardown := setup()
+	defer teardown()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	mux.HandleFunc("/"+tokenResourceList+"/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Link", fmt.Sprintf(`<%s/?page=2>; rel="next"`, serverURL))
+		fmt.Fprint(w, `[{"metadata":{"uid":"1"}}]`)
+		cancel()
+	})
+
+	tokens, err := client.Token.ListAll(ctx, nil)
+	if err != context.Canceled {
+		t.Errorf("Tokens.ListAll returned error %v, want %v", err, context.Canceled)
+	}
+	if len(tokens) != 1 {
+		t.Errorf("Tokens.ListAll returned %d tokens, want 1", len(tokens))
+	}
+}
+
+func TestTokensService_ListAll_canceledRetry(t *testing.T) {
+	client, mux, serverURL, teardown := setup()
+	defer teardown()
+	client.RetryPolicy = &RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var page2 int
+	mux.HandleFunc("/"+tokenResourceList+"/", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("page") == "2" {
+			page2++
+			cancel()
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%s/?page=2>; rel="next"`, serverURL))
+		fmt.Fprint(w, `[{"metadata":{"uid":"1"}}]`)
+	})
+
+	tokens, err := client.Token.ListAll(ctx, nil)
+	if err != context.Canceled {
+		t.Errorf("Tokens.ListAll returned error %v, want %v", err, context.Canceled)
+	}
+	if len(tokens) != 1 {
+		t.Errorf("Tokens.ListAll returned %d tokens, want 1", len(tokens))
+	}
+	if page2 != 1 {
+		t.Errorf("Tokens.ListAll requested page 2 %d times, want 1", page2)
+	}
+}
+
+func TestTokensService_ListInNamespaces(t *testing.T) {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	for _, ns := range []string{"a", "b"} {
+		body := fmt.Sprintf(`[{"metadata":{"namespace":%q}}]`, ns)
+		mux.HandleFunc("/api/v1/namespace/"+ns+"/"+tokenResourceList+"/", func(w http.ResponseWriter, r *http.Request) {
+			testMethod(t, r, "GET")
+			fmt.Fprint(w, body)
+		})
+	}
+
+	client := NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + baseURLPath + "/")
+
+	got, err := client.Token.ListInNamespaces(context.Background(), []string{"a", "b"}, nil)
+	if err != nil {
+		t.Fatalf("Tokens.ListInNamespaces returned error: %v", err)
+	}
+
+	want := map[string][]*Token{
+		"a": {{Metadata: Metadata{Namespace: "a"}}},
+		"b": {{Metadata: Metadata{Namespace: "b"}}},
+	}
+	if !cmp.Equal(got, want) {
+		t.Errorf("Tokens.ListInNamespaces returned %+v, want %+v", got, want)
+	}
+}
+
+func TestTokensService_GetHistoryRaw(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+tokenResource+"/1/history", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		testHeader(t, r, "Accept", "application/vnd.pavedroad.v3.diff")
+		fmt.Fprint(w, "-active: true\n+active: false\n")
+	})
+
+	diff, _, err := client.Token.GetHistoryRaw(context.Background(), "1", RawOptions{Type: Diff})
+	if err != nil {
+		t.Fatalf("Tokens.GetHistoryRaw returned error: %v", err)
+	}
+	if want := "-active: true\n+active: false\n"; diff != want {
+		t.Errorf("Tokens.GetHistoryRaw returned %q, want %q", diff, want)
+	}
 }
 
+func TestTokensService_GetHistoryRaw_invalidType(t *testing.T) {
+	client := NewClient(nil)
+	if _, _, err := client.Token.GetHistoryRaw(context.Background(), "1", RawOptions{}); err == nil {
+		t.Error("Expected error to be returned for an unsupported raw type.")
+	}
+}
 
+func TestTokensService_ExpiringWithin(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	soon := time.Now().Add(time.Hour).Unix()
+	later := time.Now().Add(30 * 24 * time.Hour).Unix()
+	mux.HandleFunc("/"+tokenResourceList+"/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		before, err := time.Parse(time.RFC3339, r.FormValue("expiringBefore"))
+		if err != nil || time.Until(before) < 23*time.Hour || time.Until(before) > 25*time.Hour {
+			t.Errorf("expiringBefore = %q, want about a day from now", r.FormValue("expiringBefore"))
+		}
+		// Answer as a server ignoring the filter.
+		fmt.Fprintf(w, `[{"metadata":{"uid":"1"},"expiresAt":%d},{"metadata":{"uid":"2"},"expiresAt":%d},{"metadata":{"uid":"3"}}]`, soon, later)
+	})
+
+	tokens, err := client.Token.ExpiringWithin(context.Background(), 24*time.Hour, nil)
+	if err != nil {
+		t.Fatalf("Tokens.ExpiringWithin returned error: %v", err)
+	}
+	if len(tokens) != 1 || tokens[0].Metadata.UID != "1" {
+		t.Errorf("Tokens.ExpiringWithin returned %v, want token 1", tokens)
+	}
+}
+
+func TestToken_ExpiresBefore(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		token *Token
+		want  bool
+	}{
+		{&Token{}, false},
+		{&Token{ExpiresAt: &Timestamp{now.Add(-time.Minute)}}, true},
+		{&Token{ExpiresAt: &Timestamp{now.Add(time.Minute)}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.token.ExpiresBefore(now); got != tt.want {
+			t.Errorf("ExpiresBefore(%v) for %v = %v, want %v", now, tt.token.ExpiresAt, got, tt.want)
+		}
+	}
+}
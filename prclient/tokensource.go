@@ -0,0 +1,164 @@
+package prclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// tokenSourceTTL is how long a secret served by TokensService.TokenSource
+// is cached before it is fetched again, so secrets edited in place are
+// picked up. Secrets expiring sooner are cached until Token.ExpiresAt.
+const tokenSourceTTL = 5 * time.Minute
+
+// TokenSource returns an oauth2.TokenSource serving the secret stored in
+// the PavedRoad token uid as an access token. The secret is cached and
+// fetched again once it expires. ctx is used for these fetches.
+//
+// The token source is bound to uid and does not follow rotations: once
+// TokensService.Rotate deactivates that token, the token source fails when
+// its cached secret expires, and callers must build a new one with the UID
+// of TokenRotation.New. The same holds for Transport.
+func (s *TokensService) TokenSource(ctx context.Context, uid string) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, &tokenSource{ctx: ctx, s: s, uid: uid})
+}
+
+type tokenSource struct {
+	ctx context.Context
+	s   *TokensService
+	uid string
+}
+
+// Token implements oauth2.TokenSource.
+func (ts *tokenSource) Token() (*oauth2.Token, error) {
+	t, _, err := ts.s.Get(ts.ctx, ts.uid)
+	if err != nil {
+		return nil, err
+	}
+	return oauth2Token(t)
+}
+
+// oauth2Token converts a PavedRoad token to an oauth2.Token.
+func oauth2Token(t *Token) (*oauth2.Token, error) {
+	if !t.Active {
+		return nil, fmt.Errorf("token %s is not active", t.Metadata.UID)
+	}
+	if t.Metadata.Token == "" {
+		return nil, fmt.Errorf("token %s has no secret", t.Metadata.UID)
+	}
+	expiry := time.Now().Add(tokenSourceTTL)
+	if t.ExpiresBefore(expiry) {
+		if t.ExpiresBefore(time.Now()) {
+			return nil, fmt.Errorf("token %s expired at %v", t.Metadata.UID, t.ExpiresAt)
+		}
+		expiry = t.ExpiresAt.Time
+	}
+	return &oauth2.Token{
+		AccessToken: t.Metadata.Token,
+		Expiry:      expiry,
+	}, nil
+}
+
+// Transport returns a TokenTransport authenticating the requests sent to
+// the site of the PavedRoad token uid, as given by its Metadata.EndPoint,
+// with its secret. base is the underlying transport and may be nil.
+//
+// For example, a GitHub client can be configured with:
+//
+//	tr, err := client.Token.Transport(ctx, uid, nil)
+//	gh := github.NewClient(tr.Client())
+func (s *TokensService) Transport(ctx context.Context, uid string, base http.RoundTripper) (*TokenTransport, error) {
+	t, _, err := s.Get(ctx, uid)
+	if err != nil {
+		return nil, err
+	}
+	tok, err := oauth2Token(t)
+	if err != nil {
+		return nil, err
+	}
+	if t.Metadata.EndPoint == "" {
+		return nil, fmt.Errorf("token %s has no endpoint", uid)
+	}
+	return &TokenTransport{
+		Source:    oauth2.ReuseTokenSource(tok, &tokenSource{ctx: ctx, s: s, uid: uid}),
+		EndPoint:  t.Metadata.EndPoint,
+		Transport: base,
+	}, nil
+}
+
+// TokenTransport is an http.RoundTripper that authenticates the requests
+// sent to EndPoint with the access token of Source. Requests to other
+// hosts, or with another scheme, are sent unchanged, so the credential
+// never leaks to them, e.g. when following a redirect.
+type TokenTransport struct {
+	Source oauth2.TokenSource
+
+	// EndPoint is the site the credential is for, either as a URL such
+	// as https://api.github.com or as a host name, which is only reached
+	// over https.
+	EndPoint string
+
+	// Transport is the underlying HTTP transport to use when making requests.
+	// It will default to http.DefaultTransport if nil.
+	Transport http.RoundTripper
+}
+
+// RoundTrip implements the RoundTripper interface.
+func (t *TokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.matches(req.URL) {
+		return t.transport().RoundTrip(req)
+	}
+
+	tok, err := t.Source.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	// Copy the request so the one we were given is not modified, as
+	// required by the specification of http.RoundTripper.
+	req2 := req.Clone(req.Context())
+	tok.SetAuthHeader(req2)
+	return t.transport().RoundTrip(req2)
+}
+
+// matches reports whether u targets the host of EndPoint with its scheme,
+// https by default.
+func (t *TokenTransport) matches(u *url.URL) bool {
+	host, scheme := t.EndPoint, "https"
+	if strings.Contains(host, "://") {
+		e, err := url.Parse(host)
+		if err != nil {
+			return false
+		}
+		host, scheme = e.Host, e.Scheme
+	}
+	if !strings.EqualFold(u.Scheme, scheme) {
+		return false
+	}
+	host = strings.SplitN(host, "/", 2)[0]
+	if host == "" {
+		return false
+	}
+	if strings.Contains(host, ":") {
+		return strings.EqualFold(u.Host, host)
+	}
+	return strings.EqualFold(u.Hostname(), host)
+}
+
+// Client returns an *http.Client that makes requests that are authenticated
+// with the token of t.
+func (t *TokenTransport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+func (t *TokenTransport) transport() http.RoundTripper {
+	if t.Transport != nil {
+		return t.Transport
+	}
+	return http.DefaultTransport
+}
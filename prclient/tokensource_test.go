@@ -0,0 +1,128 @@
+package prclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestTokensService_TokenSource(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	var gets int
+	mux.HandleFunc("/"+tokenResource+"/1", func(w http.ResponseWriter, r *http.Request) {
+		gets++
+		fmt.Fprint(w, `{"metadata":{"uid":"1","token":"s3cr3t"},"active":true}`)
+	})
+
+	ts := client.Token.TokenSource(context.Background(), "1")
+	for i := 0; i < 2; i++ {
+		tok, err := ts.Token()
+		if err != nil {
+			t.Fatalf("TokenSource.Token returned error: %v", err)
+		}
+		if tok.AccessToken != "s3cr3t" || !tok.Valid() {
+			t.Errorf("TokenSource.Token returned %+v", tok)
+		}
+	}
+	if gets != 1 {
+		t.Errorf("token fetched %d times, want 1", gets)
+	}
+}
+
+func TestTokensService_TokenSource_inactive(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+tokenResource+"/1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"metadata":{"uid":"1","token":"s3cr3t"},"active":false}`)
+	})
+
+	if _, err := client.Token.TokenSource(context.Background(), "1").Token(); err == nil {
+		t.Error("Expected error to be returned for an inactive token.")
+	}
+}
+
+func TestTokensService_Transport(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Header.Get("Authorization"))
+	}))
+	defer site.Close()
+
+	mux.HandleFunc("/"+tokenResource+"/1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"metadata":{"uid":"1","endPoint":%q,"token":"s3cr3t"},"active":true}`, site.URL)
+	})
+
+	tr, err := client.Token.Transport(context.Background(), "1", nil)
+	if err != nil {
+		t.Fatalf("Tokens.Transport returned error: %v", err)
+	}
+	resp, err := tr.Client().Get(site.URL + "/user")
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var got string
+	fmt.Fscan(resp.Body, &got, &got)
+	if got != "s3cr3t" {
+		t.Errorf("Authorization header credential = %q, want %q", got, "s3cr3t")
+	}
+}
+
+func TestTokenTransport_matches(t *testing.T) {
+	tests := []struct {
+		endPoint, url string
+		want          bool
+	}{
+		{"https://api.github.com", "https://api.github.com/user", true},
+		{"api.github.com", "https://API.github.com/user", true},
+		{"api.github.com/v3", "https://api.github.com/user", true},
+		{"https://api.github.com", "https://evil.example.com/user", false},
+		{"localhost:8080", "http://localhost:9090/", false},
+		{"https://api.github.com", "http://api.github.com/user", false},
+		{"api.github.com", "http://api.github.com/user", false},
+		{"http://localhost:8080", "http://localhost:8080/", true},
+		{"", "https://api.github.com/user", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		tr := &TokenTransport{EndPoint: tt.endPoint}
+		if got := tr.matches(u); got != tt.want {
+			t.Errorf("matches(%q, %q) = %v, want %v", tt.endPoint, tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestTokensService_TokenSource_expiry(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	expiresAt := time.Now().Add(time.Minute).Unix()
+	mux.HandleFunc("/"+tokenResource+"/1", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"metadata":{"uid":"1","token":"s3cr3t"},"active":true,"expiresAt":%d}`, expiresAt)
+	})
+	mux.HandleFunc("/"+tokenResource+"/2", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"metadata":{"uid":"2","token":"s3cr3t"},"active":true,"expiresAt":1}`)
+	})
+
+	tok, err := client.Token.TokenSource(context.Background(), "1").Token()
+	if err != nil {
+		t.Fatalf("TokenSource.Token returned error: %v", err)
+	}
+	if tok.Expiry.Unix() != expiresAt {
+		t.Errorf("TokenSource.Token expiry = %v, want %v", tok.Expiry, time.Unix(expiresAt, 0))
+	}
+
+	if _, err := client.Token.TokenSource(context.Background(), "2").Token(); err == nil {
+		t.Error("Expected error to be returned for an expired token.")
+	}
+}
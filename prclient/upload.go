@@ -0,0 +1,200 @@
+package prclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// UploadsService handles uploading files to the PavedRoad upload API,
+// relative to Client.UploadURL.
+type UploadsService service
+
+// Asset describes a file stored by the PavedRoad upload API.
+type Asset struct {
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Label       string     `json:"label"`
+	ContentType string     `json:"contentType"`
+	Size        int64      `json:"size"`
+	URL         string     `json:"url"`
+	Created     *Timestamp `json:"created,omitempty"`
+}
+
+func (a Asset) String() string {
+	return Stringify(a)
+}
+
+// uploadStatus is returned with 202 Accepted for every chunk of a chunked
+// upload but the last one.
+type uploadStatus struct {
+	UploadID string `json:"uploadID"`
+	Offset   int64  `json:"offset"`
+}
+
+// UploadInterruptedError occurs when a chunked upload fails part way. The
+// upload can be resumed by calling Upload again with the UploadID and Offset
+// of the error copied into the UploadOptions.
+type UploadInterruptedError struct {
+	UploadID string // upload to resume, empty if no chunk was accepted
+	Offset   int64  // number of bytes accepted by PavedRoad
+	Err      error  // error that interrupted the upload
+}
+
+func (e *UploadInterruptedError) Error() string {
+	return fmt.Sprintf("upload interrupted at byte %d: %v", e.Offset, e.Err)
+}
+
+func (e *UploadInterruptedError) Unwrap() error {
+	return e.Err
+}
+
+// Upload sends file to path, relative to Client.UploadURL. opt.Name and
+// opt.Label are sent as query parameters. The media type is taken from
+// opt.MediaType, or else detected from the file name extension or content.
+//
+// If opt.ChunkSize is set and the file is larger, it is sent in chunks,
+// each carrying a Content-Range header. A non-zero opt.Offset resumes an
+// upload: only the bytes from that offset on are sent. PavedRoad answers
+// 202 Accepted to every chunk but the last; if one fails, an
+// *UploadInterruptedError tells where to resume from.
+func (s *UploadsService) Upload(ctx context.Context, path string, file *os.File, opt *UploadOptions) (*Asset, *Response, error) {
+	var o UploadOptions
+	if opt != nil {
+		o = *opt
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if stat.IsDir() {
+		return nil, nil, errors.New("the asset to upload can't be a directory")
+	}
+	size := stat.Size()
+	switch {
+	case o.Offset < 0 || o.Offset > size:
+		return nil, nil, fmt.Errorf("offset %d is outside of the %d bytes of the file", o.Offset, size)
+	case o.Offset == size && size > 0:
+		return nil, nil, fmt.Errorf("offset %d leaves nothing to upload", o.Offset)
+	}
+
+	mediaType := o.MediaType
+	if mediaType == "" {
+		mediaType = detectMediaType(file)
+	}
+
+	if o.ChunkSize <= 0 || (size <= o.ChunkSize && o.Offset == 0) {
+		asset := new(Asset)
+		resp, err := s.send(ctx, path, file, &o, o.Offset, size-o.Offset, size, mediaType, asset)
+		if err != nil {
+			return nil, resp, err
+		}
+		return asset, resp, nil
+	}
+
+	for offset := o.Offset; ; {
+		n := o.ChunkSize
+		if size-offset < n {
+			n = size - offset
+		}
+
+		asset := new(Asset)
+		resp, err := s.send(ctx, path, file, &o, offset, n, size, mediaType, asset)
+
+		var aerr *AcceptedError
+		switch {
+		case errors.As(err, &aerr):
+			var status uploadStatus
+			if len(aerr.Raw) > 0 {
+				if err := json.Unmarshal(aerr.Raw, &status); err != nil {
+					return nil, resp, &UploadInterruptedError{UploadID: o.UploadID, Offset: offset, Err: err}
+				}
+			}
+			if status.UploadID != "" {
+				o.UploadID = status.UploadID
+			}
+			if status.Offset > offset {
+				offset = status.Offset
+			} else {
+				offset += n
+			}
+			if offset >= size {
+				return nil, resp, &UploadInterruptedError{UploadID: o.UploadID, Offset: offset,
+					Err: errors.New("upload was not completed after its last chunk")}
+			}
+		case err != nil:
+			return nil, resp, &UploadInterruptedError{UploadID: o.UploadID, Offset: offset, Err: err}
+		default:
+			return asset, resp, nil
+		}
+	}
+}
+
+// send uploads n bytes of file starting at offset and decodes the response
+// into v. Chunks, i.e. parts of the file, carry a Content-Range header.
+func (s *UploadsService) send(ctx context.Context, path string, file *os.File, opt *UploadOptions, offset, n, size int64, mediaType string, v interface{}) (*Response, error) {
+	u, err := addOptions(path, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	body := func() io.ReadCloser {
+		return ioutil.NopCloser(&progressReader{
+			r:     io.NewSectionReader(file, offset, n),
+			sent:  offset,
+			total: size,
+			fn:    opt.Progress,
+		})
+	}
+
+	req, err := s.client.NewUploadRequest(u, body(), n, mediaType)
+	if err != nil {
+		return nil, err
+	}
+	req.GetBody = func() (io.ReadCloser, error) { return body(), nil }
+	if n != size {
+		req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+n-1, size))
+	}
+
+	return s.client.Do(ctx, req, v)
+}
+
+// detectMediaType guesses the media type of file from its name extension,
+// and falls back to sniffing its first bytes.
+func detectMediaType(file *os.File) string {
+	if t := mime.TypeByExtension(filepath.Ext(file.Name())); t != "" {
+		return t
+	}
+
+	buf := make([]byte, 512)
+	n, err := file.ReadAt(buf, 0)
+	if n == 0 && err != nil {
+		return defaultMediaType
+	}
+	return http.DetectContentType(buf[:n])
+}
+
+// progressReader reports the bytes read through r to fn.
+type progressReader struct {
+	r     io.Reader
+	sent  int64
+	total int64
+	fn    func(sent, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 && p.fn != nil {
+		p.sent += int64(n)
+		p.fn(p.sent, p.total)
+	}
+	return n, err
+}
@@ -0,0 +1,199 @@
+package prclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+)
+
+func TestUploadsService_Upload(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	file, dir, err := openTestFile("upload.txt", "Upload me !\n")
+	if err != nil {
+		t.Fatalf("Unable to create temp file: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer file.Close()
+
+	mux.HandleFunc("/assets/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		testHeader(t, r, "Content-Type", "text/plain; charset=utf-8")
+		testHeader(t, r, "Content-Range", "")
+		testFormValues(t, r, values{"name": "n", "label": "l"})
+		testBody(t, r, "Upload me !\n")
+		fmt.Fprint(w, `{"id":"a-1","name":"n","size":12}`)
+	})
+
+	var sent, total int64
+	opt := &UploadOptions{Name: "n", Label: "l", Progress: func(s, t int64) { sent, total = s, t }}
+	asset, _, err := client.Uploads.Upload(context.Background(), "assets/", file, opt)
+	if err != nil {
+		t.Fatalf("Uploads.Upload returned error: %v", err)
+	}
+	if asset.ID != "a-1" || asset.Size != 12 {
+		t.Errorf("Uploads.Upload returned %+v", asset)
+	}
+	if sent != 12 || total != 12 {
+		t.Errorf("Progress reported %d/%d, want 12/12", sent, total)
+	}
+}
+
+func TestUploadsService_Upload_mediaTypeOption(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	file, dir, err := openTestFile("upload", "{}")
+	if err != nil {
+		t.Fatalf("Unable to create temp file: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer file.Close()
+
+	mux.HandleFunc("/assets/", func(w http.ResponseWriter, r *http.Request) {
+		testHeader(t, r, "Content-Type", "application/json")
+		fmt.Fprint(w, `{}`)
+	})
+
+	opt := &UploadOptions{MediaType: "application/json"}
+	if _, _, err := client.Uploads.Upload(context.Background(), "assets/", file, opt); err != nil {
+		t.Errorf("Uploads.Upload returned error: %v", err)
+	}
+}
+
+func TestUploadsService_Upload_directory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pr-test")
+	if err != nil {
+		t.Fatalf("Unable to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file, err := os.Open(dir)
+	if err != nil {
+		t.Fatalf("Unable to open temp dir: %v", err)
+	}
+	defer file.Close()
+
+	if _, _, err := NewClient(nil).Uploads.Upload(context.Background(), "assets/", file, nil); err == nil {
+		t.Error("Expected error to be returned for a directory.")
+	}
+}
+
+func TestUploadsService_Upload_chunked(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	file, dir, err := openTestFile("upload.bin", "0123456789")
+	if err != nil {
+		t.Fatalf("Unable to create temp file: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer file.Close()
+
+	var ranges, bodies, ids []string
+	fail := true
+	mux.HandleFunc("/assets/", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		ranges = append(ranges, r.Header.Get("Content-Range"))
+		bodies = append(bodies, string(b))
+		ids = append(ids, r.FormValue("upload_id"))
+
+		switch len(ranges) {
+		case 1:
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, `{"uploadID":"u-1","offset":4}`)
+		case 2:
+			if fail {
+				fail = false
+				http.Error(w, "Bad Gateway", http.StatusBadGateway)
+				return
+			}
+		case 3:
+			w.WriteHeader(http.StatusAccepted)
+			fmt.Fprint(w, `{"uploadID":"u-1","offset":8}`)
+		case 4:
+			fmt.Fprint(w, `{"id":"a-1","size":10}`)
+		}
+	})
+
+	opt := &UploadOptions{ChunkSize: 4}
+	_, _, err = client.Uploads.Upload(context.Background(), "assets/", file, opt)
+
+	var ierr *UploadInterruptedError
+	if !errors.As(err, &ierr) {
+		t.Fatalf("Uploads.Upload returned error %v, want *UploadInterruptedError", err)
+	}
+	if ierr.UploadID != "u-1" || ierr.Offset != 4 {
+		t.Errorf("Uploads.Upload interrupted at %+v, want u-1 at 4", ierr)
+	}
+
+	opt.UploadID, opt.Offset = ierr.UploadID, ierr.Offset
+	asset, _, err := client.Uploads.Upload(context.Background(), "assets/", file, opt)
+	if err != nil {
+		t.Fatalf("Uploads.Upload returned error: %v", err)
+	}
+	if asset.ID != "a-1" {
+		t.Errorf("Uploads.Upload returned %+v", asset)
+	}
+
+	wantRanges := []string{"bytes 0-3/10", "bytes 4-7/10", "bytes 4-7/10", "bytes 8-9/10"}
+	wantBodies := []string{"0123", "4567", "4567", "89"}
+	wantIDs := []string{"", "u-1", "u-1", "u-1"}
+	for i := range wantRanges {
+		if ranges[i] != wantRanges[i] || bodies[i] != wantBodies[i] || ids[i] != wantIDs[i] {
+			t.Errorf("chunk %d: got %q %q %q, want %q %q %q", i,
+				ranges[i], bodies[i], ids[i], wantRanges[i], wantBodies[i], wantIDs[i])
+		}
+	}
+}
+
+func TestUploadsService_Upload_resumeWithoutChunks(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	file, dir, err := openTestFile("upload.bin", "0123456789")
+	if err != nil {
+		t.Fatalf("Unable to create temp file: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer file.Close()
+
+	mux.HandleFunc("/assets/", func(w http.ResponseWriter, r *http.Request) {
+		testHeader(t, r, "Content-Range", "bytes 6-9/10")
+		testBody(t, r, "6789")
+		fmt.Fprint(w, `{"id":"a-1","size":10}`)
+	})
+
+	opt := &UploadOptions{UploadID: "u-1", Offset: 6}
+	if _, _, err := client.Uploads.Upload(context.Background(), "assets/", file, opt); err != nil {
+		t.Fatalf("Uploads.Upload returned error: %v", err)
+	}
+}
+
+func TestUploadsService_Upload_invalidOffset(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	file, dir, err := openTestFile("upload.bin", "0123456789")
+	if err != nil {
+		t.Fatalf("Unable to create temp file: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	defer file.Close()
+
+	mux.HandleFunc("/assets/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request with Content-Range %q", r.Header.Get("Content-Range"))
+	})
+
+	for _, offset := range []int64{-1, 10, 11} {
+		opt := &UploadOptions{ChunkSize: 4, Offset: offset}
+		if _, _, err := client.Uploads.Upload(context.Background(), "assets/", file, opt); err == nil {
+			t.Errorf("Uploads.Upload with offset %d returned no error", offset)
+		}
+	}
+}
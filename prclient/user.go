@@ -0,0 +1,129 @@
+/*
+User implements access to prUser microservice which stores PavedRoad user
+accounts.
+
+HTTP verbs are translated into the following function calls:
+
+Verbs to Functions
+------   ---------
+POST     Create
+GET      Get
+GET/     List resources
+PUT      Replace
+DELETE   Delete
+PATCH    Edit, Patch
+*/
+package prclient
+
+import (
+	"context"
+	"errors"
+)
+
+// UsersService handles communication with the user related
+// methods of the PavedRoad API.
+type UsersService service
+
+// User data structure for PavedRoad user accounts
+type User struct {
+	APIVersion string           `json:"apiVersion"`
+	Kind       string           `json:"kind"`
+	Metadata   ResourceMetadata `json:"metadata"`
+	Login      string           `json:"login"`
+	Email      string           `json:"email"`
+	FullName   string           `json:"fullName"`
+	Created    *Timestamp       `json:"created,omitempty"`
+	Updated    *Timestamp       `json:"updated,omitempty"`
+	Active     bool             `json:"active"`
+}
+
+func (u User) String() string {
+	return Stringify(u)
+}
+
+// resource returns the generic client for prUser resources.
+func (s *UsersService) resource() *ResourceClient[User] {
+	return NewResourceClient(s.client, userResource, "UUID", func(u *User) string {
+		return u.Metadata.UID
+	})
+}
+
+// Create a user
+// PavedRoad API endpoint /prUser/
+func (s *UsersService) Create(ctx context.Context, newUser User) (*User, *Response, error) {
+	return s.resource().Create(ctx, &newUser)
+}
+
+// Get fetches a user based on a UUID.
+// PavedRoad API endpoint /prUser/uuid.
+func (s *UsersService) Get(ctx context.Context, uuid string) (*User, *Response, error) {
+	return s.resource().Get(ctx, uuid)
+}
+
+// GetByMapper fetches the user a UserIdMapper resolves to, i.e. the user
+// whose UUID is mapper.UserUUID.
+// PavedRoad API endpoint /prUser/uuid.
+func (s *UsersService) GetByMapper(ctx context.Context, mapper *UserIdMapper) (*User, *Response, error) {
+	if mapper == nil || mapper.UserUUID == "" {
+		return nil, nil, errors.New("mapper with a user UUID is required")
+	}
+	return s.Get(ctx, mapper.UserUUID)
+}
+
+// Delete a user using a UUID.
+// PavedRoad API endpoint /prUser/uuid.
+func (s *UsersService) Delete(ctx context.Context, uuid string) (*Response, error) {
+	return s.resource().Delete(ctx, uuid)
+}
+
+// Edit a user.
+// The whole user is sent, so zero values overwrite the stored ones; use
+// Patch to change only some fields.
+// PavedRoad API endpoint /prUser/uuid.
+func (s *UsersService) Edit(ctx context.Context, user *User, uuid string) (*User, *Response, error) {
+	return s.resource().Edit(ctx, user, uuid)
+}
+
+// Patch applies a MergePatch or a JSONPatch to a user.
+// PavedRoad API endpoint /prUser/uuid.
+func (s *UsersService) Patch(ctx context.Context, uuid string, patch PatchDocument) (*User, *Response, error) {
+	return s.resource().Patch(ctx, uuid, patch)
+}
+
+// Replace a user.
+// PavedRoad API endpoint /prUser/uuid.
+func (s *UsersService) Replace(ctx context.Context, user *User, uuid string) (*User, *Response, error) {
+	return s.resource().Replace(ctx, user, uuid)
+}
+
+// UserListOptions specifies optional parameters to the UsersService.List
+// and UsersService.ListAll methods.
+type UserListOptions struct {
+	// UID of the last user seen
+	Since string `url:"since,omitempty"`
+
+	// Note: when the server does not return Link headers, pagination is
+	// powered by the Since parameter and ListOptions.Page has no effect.
+	ListOptions
+}
+
+// Advance implements Pager.
+func (o *UserListOptions) Advance(resp *Response, n int, last string) bool {
+	return advancePage(&o.ListOptions, &o.Since, resp, n, last)
+}
+
+// List lists one page of PavedRoad users.
+// PavedRoad API endpoint /prUserLIST/
+func (s *UsersService) List(ctx context.Context, opt *UserListOptions) ([]*User, *Response, error) {
+	return s.resource().List(ctx, opt)
+}
+
+// ListAll walks every page of PavedRoad users starting at opt and returns
+// them all. See ResourceClient.ListAll.
+func (s *UsersService) ListAll(ctx context.Context, opt *UserListOptions) ([]*User, error) {
+	var o UserListOptions
+	if opt != nil {
+		o = *opt
+	}
+	return s.resource().ListAll(ctx, &o)
+}
@@ -0,0 +1,116 @@
+package prclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var blankUserJSON = `{"apiVersion":"1","kind":"","metadata":{"name":"","namespace":"","uid":""},"login":"","email":"","fullName":"","active":false}`
+
+func TestUser_marshall(t *testing.T) {
+	u := &User{APIVersion: "1"}
+	testJSONMarshal(t, u, blankUserJSON)
+}
+
+func TestUsersService_Get(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+userResource+"/1234", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, blankUserJSON)
+	})
+
+	user, _, err := client.Users.Get(context.Background(), "1234")
+	if err != nil {
+		t.Errorf("Users.Get returned error: %v", err)
+	}
+
+	want := &User{APIVersion: "1"}
+	if !cmp.Equal(user, want) {
+		t.Errorf("Users.Get returned %+v, want %+v", user, want)
+	}
+}
+
+func TestUsersService_GetByMapper(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+userResource+"/u-1", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, `{"metadata":{"uid":"u-1"},"login":"octocat"}`)
+	})
+
+	user, _, err := client.Users.GetByMapper(context.Background(), &UserIdMapper{Credential: "octocat", UserUUID: "u-1"})
+	if err != nil {
+		t.Errorf("Users.GetByMapper returned error: %v", err)
+	}
+
+	want := &User{Metadata: ResourceMetadata{UID: "u-1"}, Login: "octocat"}
+	if !cmp.Equal(user, want) {
+		t.Errorf("Users.GetByMapper returned %+v, want %+v", user, want)
+	}
+
+	if _, _, err := client.Users.GetByMapper(context.Background(), nil); err == nil {
+		t.Error("Expected error to be returned for a nil mapper.")
+	}
+	if _, _, err := client.Users.GetByMapper(context.Background(), &UserIdMapper{}); err == nil {
+		t.Error("Expected error to be returned for a mapper without UserUUID.")
+	}
+}
+
+func TestUsersService_Create(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+userResource+"/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "POST")
+		fmt.Fprint(w, `{"metadata":{"uid":"u-1"},"login":"octocat"}`)
+	})
+
+	user, _, err := client.Users.Create(context.Background(), User{Login: "octocat"})
+	if err != nil {
+		t.Errorf("Users.Create returned error: %v", err)
+	}
+	if user.Metadata.UID != "u-1" {
+		t.Errorf("Users.Create returned %+v, want UID u-1", user)
+	}
+}
+
+func TestUsersService_Delete(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+userResource+"/1", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "DELETE")
+	})
+
+	if _, err := client.Users.Delete(context.Background(), "1"); err != nil {
+		t.Errorf("Users.Delete returned error: %v", err)
+	}
+}
+
+func TestUsersService_List(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+userResource+"LIST/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		testFormValues(t, r, values{"per_page": "10"})
+		fmt.Fprint(w, `[{"login":"a"},{"login":"b"}]`)
+	})
+
+	users, err := client.Users.ListAll(context.Background(), &UserListOptions{ListOptions: ListOptions{PerPage: 10}})
+	if err != nil {
+		t.Errorf("Users.ListAll returned error: %v", err)
+	}
+
+	want := []*User{{Login: "a"}, {Login: "b"}}
+	if !cmp.Equal(users, want) {
+		t.Errorf("Users.ListAll returned %+v, want %+v", users, want)
+	}
+}
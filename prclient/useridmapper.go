@@ -7,18 +7,19 @@ Verbs to    keys                       Functions
 ----------  -------------------------- ---------
 POST                                   Create
 GET         /credential                Get
-GET         /prUserIdMappersLIST       List resources
+GET         /prUserIdMappersLIST       List resources, Watch
 PUT         /credential                Replace
 DELETE      /credential                Delete
-PATCH       /credential                Edit
-
+PATCH       /credential                Edit, Patch
 */
 package prclient
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
+	"io"
 )
 
 // UserIdMappersService handles communication with the token related
@@ -27,157 +28,204 @@ type UserIdMappersService service
 
 // prUserIdMapper data structure for token storage
 type UserIdMapper struct {
-  APIVersion string `json:"apiVersion"`
-  ObjVersion string `json:"objVersion"`
-  Kind       string `json:"kind"`
-  Credential string `json:"login"`
-  UserUUID   string `json:"userUUID"`
-  LoginCount int    `json:"loginCount"`
-  Created    string `json:"created,ignoreempty"`
-  Updated    string `json:"updated,ignoreempty"`
-  Active     string `json:"active"`
+	APIVersion string     `json:"apiVersion"`
+	ObjVersion string     `json:"objVersion"`
+	Kind       string     `json:"kind"`
+	Credential string     `json:"login" pr:"secret"`
+	UserUUID   string     `json:"userUUID"`
+	LoginCount int        `json:"loginCount"`
+	Created    *Timestamp `json:"created,omitempty"`
+	Updated    *Timestamp `json:"updated,omitempty"`
+	Active     string     `json:"active"`
 }
 
 func (u UserIdMapper) String() string {
 	return Stringify(u)
 }
 
+// resource returns the generic client for prUserIdMappers resources.
+func (s *UserIdMappersService) resource() *ResourceClient[UserIdMapper] {
+	return NewResourceClient(s.client, mapperResource, "credential", func(m *UserIdMapper) string {
+		return m.Credential
+	})
+}
+
 // Create a token
 // PavedRoad API endpoint /prUserIdMappers/
 func (s *UserIdMappersService) Create(ctx context.Context, newUserIdMapper UserIdMapper) (*UserIdMapper, *Response, error) {
-	var u = fmt.Sprintf("%s/", mapperResource)
-
-	req, err := s.client.NewRequest("POST", u, newUserIdMapper)
-	if err != nil {
-		return nil, nil, err
-	}
-
-  rUserIdMapper := &UserIdMapper{}
-	resp, err := s.client.Do(ctx, req, rUserIdMapper)
-	if err != nil {
-		return nil, resp, err
-	}
-
-	return rUserIdMapper, resp, nil
+	return s.resource().Create(ctx, &newUserIdMapper)
 }
 
-// Get fetches a token using based on a Ucredential.
+// Get fetches a token using based on a credential.
 // PavedRoad API endpoint /prUserIdMappers/credential.
 func (s *UserIdMappersService) Get(ctx context.Context, cred string) (*UserIdMapper, *Response, error) {
-	var u string
-	if cred != "" {
-		u = fmt.Sprintf("%s/%v", mapperResource, cred)
-	} else {
-		return nil, nil, errors.New("Ucredential is required")
-	}
-	req, err := s.client.NewRequest("GET", u, nil)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	uResp := new(UserIdMapper)
-	resp, err := s.client.Do(ctx, req, uResp)
-	if err != nil {
-		return nil, resp, err
-	}
-
-	return uResp, resp, nil
+	return s.resource().Get(ctx, cred)
 }
 
-// Delete a token using a Ucredential.
+// Delete a token using a credential.
 // PavedRoad API endpoint /prUserIdMappers/cred.
 func (s *UserIdMappersService) Delete(ctx context.Context, cred string) (*Response, error) {
-	var u string
-
-	if cred != "" {
-		u = fmt.Sprintf("%s/%v", mapperResource, cred)
-	} else {
-		return nil, errors.New("Ucredential is required")
-	}
-	req, err := s.client.NewRequest("DELETE", u, nil)
-	if err != nil {
-		return nil, err
-	}
-	return s.client.Do(ctx, req, nil)
+	return s.resource().Delete(ctx, cred)
 }
 
 // Edit a token.
+// The whole mapper is sent, so zero values overwrite the stored ones; use
+// Patch to change only some fields.
+// If token.ObjVersion is set, the update only succeeds if the stored mapper
+// is still at that version; otherwise a *VersionConflictError is returned.
 // PavedRoad API docs: https://developer.pavedroad.io/v1/token/#update-token
 func (s *UserIdMappersService) Edit(ctx context.Context, token *UserIdMapper, cred string) (*UserIdMapper, *Response, error) {
-	var u string
-	if cred != "" {
-		u = fmt.Sprintf("%s/%v", mapperResource, cred)
-	} else {
-		return nil, nil, errors.New("Ucredential is required")
-	}
-
-	req, err := s.client.NewRequest("PATCH", u, token)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	tResp := new(UserIdMapper)
-	resp, err := s.client.Do(ctx, req, tResp)
-	if err != nil {
-		return nil, resp, err
+	if token == nil {
+		return nil, nil, errNilMapper
 	}
+	m, resp, err := s.resource().Edit(ctx, token, cred, IfMatch(token.ObjVersion))
+	return m, resp, versionConflict(err, cred, token.ObjVersion)
+}
 
-	return tResp, resp, nil
+// Patch applies a MergePatch or a JSONPatch to a mapper, e.g. one computed
+// with CreateMergePatch from the mapper as read and as modified.
+// PavedRoad API endpoint /prUserIdMappers/cred.
+func (s *UserIdMappersService) Patch(ctx context.Context, cred string, patch PatchDocument) (*UserIdMapper, *Response, error) {
+	return s.resource().Patch(ctx, cred, patch)
 }
 
 // Replace a token.
+// If token.ObjVersion is set, the update only succeeds if the stored mapper
+// is still at that version; otherwise a *VersionConflictError is returned.
 // PavedRoad API docs: https://developer.pavedroad.io/v1/token/#replace-token
 func (s *UserIdMappersService) Replace(ctx context.Context, token *UserIdMapper, cred string) (*UserIdMapper, *Response, error) {
-	var u string
-	if cred != "" {
-		u = fmt.Sprintf("%s/%v", mapperResource, cred)
-	} else {
-		return nil, nil, errors.New("Ucredential is required")
+	if token == nil {
+		return nil, nil, errNilMapper
 	}
+	m, resp, err := s.resource().Replace(ctx, token, cred, IfMatch(token.ObjVersion))
+	return m, resp, versionConflict(err, cred, token.ObjVersion)
+}
 
-	req, err := s.client.NewRequest("PUT", u, token)
-	if err != nil {
-		return nil, nil, err
+// errNilMapper is returned when a nil mapper is passed to an update.
+var errNilMapper = errors.New("mapper is required")
+
+// maxUpdateAttempts bounds the number of read-modify-write cycles made by
+// UserIdMappersService.UpdateWithRetry.
+const maxUpdateAttempts = 5
+
+// UpdateWithRetry reads the mapper for cred, applies mutate to it and
+// replaces it, conditional on the version read. If another writer updated
+// the mapper in between, the cycle is repeated with a fresh copy, up to a
+// few times. An error returned by mutate aborts the update.
+func (s *UserIdMappersService) UpdateWithRetry(ctx context.Context, cred string, mutate func(*UserIdMapper) error) (*UserIdMapper, *Response, error) {
+	var err error
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		m, resp, gerr := s.Get(ctx, cred)
+		if gerr != nil {
+			return nil, resp, gerr
+		}
+
+		if merr := mutate(m); merr != nil {
+			return nil, resp, merr
+		}
+
+		var updated *UserIdMapper
+		updated, resp, err = s.Replace(ctx, m, cred)
+		if !errors.Is(err, ErrConflict) {
+			return updated, resp, err
+		}
+
+		if cerr := ctx.Err(); cerr != nil {
+			return nil, resp, cerr
+		}
 	}
+	return nil, nil, err
+}
 
-	tResp := new(UserIdMapper)
-	resp, err := s.client.Do(ctx, req, tResp)
-	if err != nil {
-		return nil, resp, err
+// VersionConflictError occurs when a conditional update is rejected because
+// the stored object is no longer at the version the update was based on.
+// It matches ErrConflict with errors.Is.
+type VersionConflictError struct {
+	Key        string // key of the object, e.g. the credential of a mapper
+	ObjVersion string // version the update was based on
+	Err        error  // error returned by the API
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("object was modified since version %s: %v", e.ObjVersion, e.Err)
+}
+
+func (e *VersionConflictError) Unwrap() error {
+	return e.Err
+}
+
+// versionConflict wraps err in a *VersionConflictError if it reports that a
+// conditional update of key based on version was rejected.
+func versionConflict(err error, key, version string) error {
+	if version == "" || !errors.Is(err, ErrConflict) {
+		return err
 	}
+	return &VersionConflictError{Key: key, ObjVersion: version, Err: err}
+}
 
-	return tResp, resp, nil
+// GetHistoryRaw returns the history of a mapper in the raw format
+// given by opt, e.g. a diff between its versions.
+// PavedRoad API endpoint /prUserIdMappers/cred/history.
+func (s *UserIdMappersService) GetHistoryRaw(ctx context.Context, cred string, opt RawOptions) (string, *Response, error) {
+	var buf bytes.Buffer
+	resp, err := s.StreamHistoryRaw(ctx, cred, opt, &buf)
+	if err != nil {
+		return "", resp, err
+	}
+	return buf.String(), resp, nil
 }
 
+// StreamHistoryRaw writes the history of a mapper to w in the raw
+// format given by opt, without buffering it.
+// PavedRoad API endpoint /prUserIdMappers/cred/history.
+func (s *UserIdMappersService) StreamHistoryRaw(ctx context.Context, cred string, opt RawOptions, w io.Writer) (*Response, error) {
+	return s.resource().GetRaw(ctx, cred, "history", opt, w)
+}
 
-// UserIdMapperListOptions specifies optional parameters to the UserIdMappersService.ListAll
-// method.
+// UserIdMapperListOptions specifies optional parameters to the
+// UserIdMappersService.List and UserIdMappersService.ListAll methods.
 type UserIdMapperListOptions struct {
-       // ID of the last token seen
-       Since int64 `url:"since,omitempty"`
+	// Credential of the last mapper seen
+	Since string `url:"since,omitempty"`
 
-       // Note: Pagination is powered exclusively by the Since parameter,
-       // ListOptions.Page has no effect.
-       // ListOptions.PerPage controls an undocumented PavedRoad API parameter.
-       ListOptions
+	// Note: when the server does not return Link headers, pagination is
+	// powered by the Since parameter and ListOptions.Page has no effect.
+	ListOptions
 }
 
-// ListAll lists all PavedRoad token.
-func (s *UserIdMappersService) List(ctx context.Context, opt *UserIdMapperListOptions) (*Response, error) {
-	var u = fmt.Sprintf("%s/", mapperResourceList)
+// Advance implements Pager.
+func (o *UserIdMapperListOptions) Advance(resp *Response, n int, last string) bool {
+	return advancePage(&o.ListOptions, &o.Since, resp, n, last)
+}
 
-  // convert to our method of paging
+// List lists one page of PavedRoad user ID mappers.
+// PavedRoad API endpoint /prUserIdMappersLIST/
+func (s *UserIdMappersService) List(ctx context.Context, opt *UserIdMapperListOptions) ([]*UserIdMapper, *Response, error) {
+	return s.resource().List(ctx, opt)
+}
 
-	req, err := s.client.NewRequest("GET", u, nil)
-	if err != nil {
-		return nil, err
+// ListAll walks every page of PavedRoad user ID mappers starting at opt and
+// returns them all. See ResourceClient.ListAll.
+func (s *UserIdMappersService) ListAll(ctx context.Context, opt *UserIdMapperListOptions) ([]*UserIdMapper, error) {
+	var o UserIdMapperListOptions
+	if opt != nil {
+		o = *opt
 	}
+	return s.resource().ListAll(ctx, &o)
+}
 
-	var token []*UserIdMapper
-	resp, err := s.client.Do(ctx, req, &token)
-	if err != nil {
-		return resp, err
-	}
+// Watch streams the changes made to the PavedRoad user ID mappers. See
+// ResourceClient.Watch.
+// PavedRoad API endpoint /prUserIdMappersLIST/?watch=true
+func (s *UserIdMappersService) Watch(ctx context.Context, opt *WatchOptions) (<-chan WatchEvent[UserIdMapper], error) {
+	return s.resource().Watch(ctx, opt)
+}
 
-	return resp, nil
+// ListInNamespaces lists every user ID mapper of each namespace in
+// namespaces, using the connection pool of the client, and returns them
+// keyed by namespace.
+func (s *UserIdMappersService) ListInNamespaces(ctx context.Context, namespaces []string, opt *UserIdMapperListOptions) (map[string][]*UserIdMapper, error) {
+	return listInNamespaces(ctx, s.client, namespaces, func(c *Client) ([]*UserIdMapper, error) {
+		return c.UserIdMapper.ListAll(ctx, opt)
+	})
 }
@@ -3,11 +3,13 @@ package prclient
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/go-cmp/cmp"
 	"net/http"
 	_ "reflect"
 	"testing"
+	"time"
 )
 
 // blankUserIdMapper is an initialized object with defaults
@@ -18,21 +20,19 @@ var blankUserIdMapperJSON = `{
   "login": "",
   "userUUID": "",
   "loginCount": 0,
-  "created": "",
-  "updated": "",
   "active": ""
 }`
 
 var blanUserIdMapperObject = UserIdMapper{
-    APIVersion: "core.pavedroad.io/v1alpha1",
-    Kind:       "prUserIdMapper",
-    ObjVersion: "v1beta1",
-    Credential: "",
-    UserUUID:   "",
-    LoginCount: 0,
-    Created:    "2002-10-02T15:00:00.05Z",
-    Updated:    "2002-10-02T15:00:00.05Z",
-    Active:     "true"}
+	APIVersion: "core.pavedroad.io/v1alpha1",
+	Kind:       "prUserIdMapper",
+	ObjVersion: "v1beta1",
+	Credential: "",
+	UserUUID:   "",
+	LoginCount: 0,
+	Created:    &Timestamp{time.Date(2002, time.October, 2, 15, 0, 0, 5e7, time.UTC)},
+	Updated:    &Timestamp{time.Date(2002, time.October, 2, 15, 0, 0, 5e7, time.UTC)},
+	Active:     "true"}
 
 func TestUserIdMapper_marshall(t *testing.T) {
 	u := &UserIdMapper{APIVersion: "1"}
@@ -71,34 +71,34 @@ func TestUserIdMappersService_Delete_specifiedUserIdMapper(t *testing.T) {
 	client, mux, _, teardown := setup()
 	defer teardown()
 
-  mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-    testMethod(t, r, "DELETE")
-  })
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "DELETE")
+	})
 
-  _, err := client.UserIdMapper.Delete(context.Background(), "1")
+	_, err := client.UserIdMapper.Delete(context.Background(), "1")
 
-  if err != nil {
-    t.Errorf("UserIdMappers delete returned error: %v", err)
-  }
+	if err != nil {
+		t.Errorf("UserIdMappers delete returned error: %v", err)
+	}
 }
 
 func TestUserIdMapperService_Replace(t *testing.T) {
-  client, mux, _, teardown := setup()
-  defer teardown()
+	client, mux, _, teardown := setup()
+	defer teardown()
 
-  input := &UserIdMapper{APIVersion: "1"}
+	input := &UserIdMapper{APIVersion: "1"}
 
-  mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-    v := &UserIdMapper{APIVersion: "1"}
-    json.NewDecoder(r.Body).Decode(v)
-    testMethod(t, r, "PUT")
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		v := &UserIdMapper{APIVersion: "1"}
+		json.NewDecoder(r.Body).Decode(v)
+		testMethod(t, r, "PUT")
 		fmt.Fprint(w, blankUserIdMapperJSON)
-  })
+	})
 
-  token, _, err := client.UserIdMapper.Replace(context.Background(), input, "foo")
-  if err != nil {
-    t.Errorf("UserIdMapper.Replace returned error: %v", err)
-  }
+	token, _, err := client.UserIdMapper.Replace(context.Background(), input, "foo")
+	if err != nil {
+		t.Errorf("UserIdMapper.Replace returned error: %v", err)
+	}
 
 	want := &UserIdMapper{APIVersion: "1"}
 	if !cmp.Equal(token, want) {
@@ -109,16 +109,119 @@ func TestUserIdMapperService_Replace(t *testing.T) {
 func TestUserIdMappersService_List_UserIdMappers(t *testing.T) {
 	client, mux, _, teardown := setup()
 	defer teardown()
-  var opt *UserIdMapperListOptions
+	var opt *UserIdMapperListOptions
 
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		testMethod(t, r, "GET")
-    fmt.Fprint(w, "")
+		fmt.Fprint(w, "")
 	})
 
-  //var u = fmt.Sprintf("%s/", tokenResourceList)
-  _, err := client.UserIdMapper.List(context.Background(), opt )
+	_, _, err := client.UserIdMapper.List(context.Background(), opt)
 	if err != nil {
 		t.Errorf("UserIdMappers.List returned error: %v", err)
 	}
 }
+
+func TestUserIdMapperService_Replace_ifMatch(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+mapperResource+"/foo", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "PUT")
+		testHeader(t, r, "If-Match", "3")
+		w.WriteHeader(http.StatusPreconditionFailed)
+	})
+
+	_, _, err := client.UserIdMapper.Replace(context.Background(), &UserIdMapper{ObjVersion: "3"}, "foo")
+
+	var verr *VersionConflictError
+	if !errors.As(err, &verr) {
+		t.Fatalf("UserIdMapper.Replace returned error %#v, want *VersionConflictError", err)
+	}
+	if verr.ObjVersion != "3" || !errors.Is(err, ErrConflict) {
+		t.Errorf("UserIdMapper.Replace returned %+v", verr)
+	}
+}
+
+func TestUserIdMapperService_Edit_noVersion(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+mapperResource+"/foo", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "PATCH")
+		testHeader(t, r, "If-Match", "")
+		fmt.Fprint(w, blankUserIdMapperJSON)
+	})
+
+	if _, _, err := client.UserIdMapper.Edit(context.Background(), &UserIdMapper{}, "foo"); err != nil {
+		t.Errorf("UserIdMapper.Edit returned error: %v", err)
+	}
+}
+
+func TestUserIdMapperService_nilMapper(t *testing.T) {
+	client := NewClient(nil)
+
+	if _, _, err := client.UserIdMapper.Edit(context.Background(), nil, "foo"); err == nil {
+		t.Error("Expected error to be returned for Edit.")
+	}
+	if _, _, err := client.UserIdMapper.Replace(context.Background(), nil, "foo"); err == nil {
+		t.Error("Expected error to be returned for Replace.")
+	}
+}
+
+func TestUserIdMapperService_UpdateWithRetry(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	stored := UserIdMapper{Credential: "foo", ObjVersion: "1", LoginCount: 1}
+	puts := 0
+	mux.HandleFunc("/"+mapperResource+"/foo", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			json.NewEncoder(w).Encode(stored)
+		case "PUT":
+			puts++
+			if puts == 1 {
+				// a concurrent writer got there first
+				stored.LoginCount++
+				stored.ObjVersion = "2"
+			}
+			if r.Header.Get("If-Match") != stored.ObjVersion {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+			json.NewDecoder(r.Body).Decode(&stored)
+			stored.ObjVersion = "3"
+			json.NewEncoder(w).Encode(stored)
+		}
+	})
+
+	m, _, err := client.UserIdMapper.UpdateWithRetry(context.Background(), "foo", func(m *UserIdMapper) error {
+		m.LoginCount++
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UserIdMapper.UpdateWithRetry returned error: %v", err)
+	}
+	if m.LoginCount != 3 || m.ObjVersion != "3" || puts != 2 {
+		t.Errorf("UserIdMapper.UpdateWithRetry returned %+v after %d PUTs", m, puts)
+	}
+}
+
+func TestUserIdMapperService_UpdateWithRetry_mutateError(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+mapperResource+"/foo", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		fmt.Fprint(w, blankUserIdMapperJSON)
+	})
+
+	want := errors.New("no")
+	_, _, err := client.UserIdMapper.UpdateWithRetry(context.Background(), "foo", func(*UserIdMapper) error {
+		return want
+	})
+	if err != want {
+		t.Errorf("UserIdMapper.UpdateWithRetry returned error %v, want %v", err, want)
+	}
+}
@@ -0,0 +1,390 @@
+package prclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// EventType is the type of a WatchEvent.
+type EventType string
+
+// Types of the events sent by PavedRoad watches.
+const (
+	EventAdded    EventType = "ADDED"
+	EventModified EventType = "MODIFIED"
+	EventDeleted  EventType = "DELETED"
+	EventError    EventType = "ERROR"
+)
+
+// ErrWatchExpired is matched by the error of a watch whose resource version
+// is too old for the server to resume from. List the objects again and
+// start a new watch.
+var ErrWatchExpired = errors.New("prclient: watch resource version expired")
+
+// WatchEvent is a change of an object of type T.
+type WatchEvent[T any] struct {
+	Type EventType
+
+	// Object is the object added or modified, or the last state of the
+	// object deleted. For an EventError, it is the object the service
+	// could not decode, e.g. a token whose secret cannot be decrypted, and
+	// nil otherwise.
+	Object *T
+
+	// ResourceVersion identifies the event; a watch started from it
+	// resumes after the event.
+	ResourceVersion string
+
+	// Err is the error of an EventError.
+	Err error
+}
+
+// WatchOptions specifies optional parameters to the Watch methods.
+type WatchOptions struct {
+	// ResourceVersion starts the watch after the event of that version.
+	// If empty, the server starts from the current state.
+	ResourceVersion string
+
+	// MinBackoff is the delay before the first reconnection after the
+	// stream broke. It doubles for every following failed attempt.
+	// Defaults to one second.
+	MinBackoff time.Duration
+
+	// MaxBackoff caps the delay between two reconnections. Defaults to
+	// 30 seconds.
+	MaxBackoff time.Duration
+
+	// established, if set, is called once the server accepted the first
+	// watch request, before any event is received.
+	established func()
+}
+
+const (
+	defaultWatchMinBackoff = time.Second
+	defaultWatchMaxBackoff = 30 * time.Second
+)
+
+// watchQuery is the query of a watch request.
+type watchQuery struct {
+	Watch           bool   `url:"watch"`
+	ResourceVersion string `url:"resourceVersion,omitempty"`
+}
+
+// Watch streams the changes made to the objects of the resource. The
+// server answers with JSON lines, one event per line, or with server-sent
+// events:
+//
+//	{"type": "ADDED", "object": {...}, "resourceVersion": "42"}
+//
+//	event: ADDED
+//	id: 42
+//	data: {...}
+//
+// Events are sent on the returned channel. When the stream breaks, or the
+// server fails with a transient error, Watch reconnects after a backoff and
+// resumes from the last resource version received. Other errors, such as
+// ErrWatchExpired or ErrNotFound for a server that cannot watch, are sent
+// as an EventError. The channel is closed after such an error or once ctx
+// is done.
+// PavedRoad API endpoint /{resource}LIST/?watch=true
+func (r *ResourceClient[T]) Watch(ctx context.Context, opt *WatchOptions) (<-chan WatchEvent[T], error) {
+	return r.watch(ctx, opt, nil)
+}
+
+// watch implements Watch. decode, if not nil, is applied to the objects
+// received; an error it returns is sent as an EventError without ending
+// the watch.
+func (r *ResourceClient[T]) watch(ctx context.Context, opt *WatchOptions, decode func(*T) error) (<-chan WatchEvent[T], error) {
+	var o WatchOptions
+	if opt != nil {
+		o = *opt
+	}
+	if o.MinBackoff <= 0 {
+		o.MinBackoff = defaultWatchMinBackoff
+	}
+	if o.MaxBackoff <= 0 {
+		o.MaxBackoff = defaultWatchMaxBackoff
+	}
+
+	w := &watcher[T]{r: r, opt: o, decode: decode, events: make(chan WatchEvent[T])}
+	if _, err := w.request(); err != nil {
+		return nil, err
+	}
+	go w.run(ctx)
+	return w.events, nil
+}
+
+// watcher is a running watch.
+type watcher[T any] struct {
+	r      *ResourceClient[T]
+	opt    WatchOptions
+	decode func(*T) error
+	events chan WatchEvent[T]
+}
+
+func (w *watcher[T]) run(ctx context.Context) {
+	defer close(w.events)
+
+	backoff := &RetryPolicy{MinBackoff: w.opt.MinBackoff, MaxBackoff: w.opt.MaxBackoff}
+	for attempt := 1; ; attempt++ {
+		received, err := w.stream(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil && !watchRetryable(err) {
+			w.send(ctx, WatchEvent[T]{Type: EventError, Err: err})
+			return
+		}
+		if received {
+			attempt = 0
+			continue
+		}
+
+		t := time.NewTimer(backoff.delay(attempt, nil))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// request returns a watch request resuming from the last resource version.
+func (w *watcher[T]) request() (*http.Request, error) {
+	u, err := addOptions(w.r.resource+"LIST/", watchQuery{true, w.opt.ResourceVersion})
+	if err != nil {
+		return nil, err
+	}
+	req, err := w.r.client.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	return req, nil
+}
+
+// stream makes a single watch request and sends the events it receives.
+// It reports whether any event was received.
+func (w *watcher[T]) stream(ctx context.Context) (received bool, err error) {
+	req, err := w.request()
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := w.r.client.bareDo(ctx, req.WithContext(ctx))
+	if err != nil {
+		return false, watchError(err)
+	}
+	defer resp.Body.Close()
+	if w.opt.established != nil {
+		w.opt.established()
+		w.opt.established = nil
+	}
+
+	err = readWatchEvents(resp.Body, func(e watchEnvelope) error {
+		event, err := w.event(e)
+		if err != nil {
+			return err
+		}
+		if event.ResourceVersion != "" {
+			w.opt.ResourceVersion = event.ResourceVersion
+		}
+		received = true
+		if !w.send(ctx, event) {
+			return ctx.Err()
+		}
+		return nil
+	})
+	if err == nil {
+		// The server ended the stream; reconnect.
+		err = io.EOF
+	}
+	return received, watchError(err)
+}
+
+// event decodes a received event. It fails for server errors, which end
+// the stream; other failures are returned as an EventError.
+func (w *watcher[T]) event(e watchEnvelope) (WatchEvent[T], error) {
+	event := WatchEvent[T]{Type: EventType(e.Type), ResourceVersion: e.ResourceVersion}
+	switch event.Type {
+	case EventAdded, EventModified, EventDeleted:
+	case EventError:
+		var status struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}
+		json.Unmarshal(e.Object, &status)
+		return event, &watchStatusError{Code: status.Code, Message: status.Message}
+	default:
+		return WatchEvent[T]{Type: EventError, ResourceVersion: e.ResourceVersion,
+			Err: fmt.Errorf("unknown watch event type %q", e.Type)}, nil
+	}
+
+	event.Object = new(T)
+	if err := json.Unmarshal(e.Object, event.Object); err != nil {
+		return WatchEvent[T]{Type: EventError, ResourceVersion: e.ResourceVersion, Err: err}, nil
+	}
+	if w.decode != nil {
+		if err := w.decode(event.Object); err != nil {
+			return WatchEvent[T]{Type: EventError, Object: event.Object, ResourceVersion: e.ResourceVersion, Err: err}, nil
+		}
+	}
+	return event, nil
+}
+
+// send sends event unless ctx is done first.
+func (w *watcher[T]) send(ctx context.Context, event WatchEvent[T]) bool {
+	select {
+	case w.events <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// watchEnvelope is an event as sent by the server.
+type watchEnvelope struct {
+	Type            string          `json:"type"`
+	Object          json.RawMessage `json:"object"`
+	ResourceVersion string          `json:"resourceVersion"`
+}
+
+// errWatchTruncated tells that a watch stream ended in the middle of an
+// event, having been cut short.
+var errWatchTruncated = errors.New("watch stream ended in the middle of an event")
+
+// scanWatchLines is like bufio.ScanLines but fails with errWatchTruncated
+// on a last line without a newline.
+func scanWatchLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) > 0 && bytes.IndexByte(data, '\n') < 0 {
+		return 0, nil, errWatchTruncated
+	}
+	return bufio.ScanLines(data, atEOF)
+}
+
+// readWatchEvents reads the events of a watch stream, in JSON lines or
+// server-sent events, and calls handle for each of them until r ends or
+// handle fails. A stream ending in the middle of an event fails with
+// errWatchTruncated.
+func readWatchEvents(r io.Reader, handle func(watchEnvelope) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20)
+	sc.Split(scanWatchLines)
+
+	// The fields of the server-sent event being read.
+	var typ, id string
+	var data bytes.Buffer
+	dispatch := func() error {
+		defer func() { typ, id = "", ""; data.Reset() }()
+		if data.Len() == 0 {
+			return nil
+		}
+		var e watchEnvelope
+		if err := json.Unmarshal(data.Bytes(), &e); err != nil {
+			return err
+		}
+		if e.Type == "" {
+			// The data is the object alone.
+			e = watchEnvelope{Type: typ, Object: append(json.RawMessage(nil), data.Bytes()...)}
+		}
+		if e.ResourceVersion == "" {
+			e.ResourceVersion = id
+		}
+		return handle(e)
+	}
+
+	for sc.Scan() {
+		line := sc.Bytes()
+		var err error
+		switch {
+		case len(bytes.TrimSpace(line)) == 0:
+			err = dispatch()
+		case line[0] == '{':
+			var e watchEnvelope
+			if err = json.Unmarshal(line, &e); err == nil {
+				err = handle(e)
+			}
+		case line[0] == ':':
+			// Comment, used as a heartbeat.
+		default:
+			field, value := line, []byte(nil)
+			if i := bytes.IndexByte(line, ':'); i >= 0 {
+				field, value = line[:i], bytes.TrimPrefix(line[i+1:], []byte(" "))
+			}
+			switch string(field) {
+			case "event":
+				typ = string(value)
+			case "id":
+				id = string(value)
+			case "data":
+				if data.Len() > 0 {
+					data.WriteByte('\n')
+				}
+				data.Write(value)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	if data.Len() > 0 {
+		// The blank line ending the last server-sent event is missing.
+		return errWatchTruncated
+	}
+	return nil
+}
+
+// watchStatusError is an error event sent by the server.
+type watchStatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *watchStatusError) Error() string {
+	return fmt.Sprintf("watch error %d: %s", e.Code, e.Message)
+}
+
+// watchError marks the errors telling that the resource version expired.
+func watchError(err error) error {
+	var se *watchStatusError
+	var er *ErrorResponse
+	if errors.As(err, &se) && se.Code == http.StatusGone ||
+		errors.As(err, &er) && er.Response != nil && er.Response.StatusCode == http.StatusGone {
+		return fmt.Errorf("%w: %w", ErrWatchExpired, err)
+	}
+	return err
+}
+
+// watchRetryable reports whether a watch that failed with err should
+// reconnect: after a broken stream, including one cut in the middle of an
+// event, a network error, or a server error other than an expired resource
+// version. A complete event that cannot be decoded is not retried.
+func watchRetryable(err error) bool {
+	if errors.Is(err, ErrWatchExpired) {
+		return false
+	}
+	var se *watchStatusError
+	if errors.As(err, &se) {
+		return se.Code >= 500 || se.Code == http.StatusTooManyRequests
+	}
+	var er *ErrorResponse
+	if errors.As(err, &er) {
+		return er.Response != nil && (er.Response.StatusCode >= 500 || er.Response.StatusCode == http.StatusTooManyRequests)
+	}
+	if errors.Is(err, ErrRateLimited) {
+		return true
+	}
+	var serr *json.SyntaxError
+	return !errors.As(err, &serr)
+}
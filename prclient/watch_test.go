@@ -0,0 +1,241 @@
+package prclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fastWatch reconnects without noticeable delay.
+var fastWatch = &WatchOptions{MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+// nextEvent returns the next event of events, failing t after a second.
+func nextEvent[T any](t *testing.T, events <-chan WatchEvent[T]) WatchEvent[T] {
+	t.Helper()
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatal("watch channel closed")
+		}
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a watch event")
+	}
+	return WatchEvent[T]{}
+}
+
+// checkClosed fails t unless events is closed within a second.
+func checkClosed[T any](t *testing.T, events <-chan WatchEvent[T]) {
+	t.Helper()
+	select {
+	case e, ok := <-events:
+		if ok {
+			t.Errorf("received event %+v, want the watch channel closed", e)
+		}
+	case <-time.After(time.Second):
+		t.Error("watch channel not closed")
+	}
+}
+
+func TestUserIdMappersService_Watch_reconnect(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	var versions []string
+	mux.HandleFunc("/"+mapperResource+"LIST/", func(w http.ResponseWriter, r *http.Request) {
+		testMethod(t, r, "GET")
+		testHeader(t, r, "Accept", "application/json, text/event-stream")
+		if r.FormValue("watch") != "true" {
+			t.Errorf("watch = %q, want true", r.FormValue("watch"))
+		}
+		versions = append(versions, r.FormValue("resourceVersion"))
+
+		if len(versions) == 1 {
+			// Break the stream after two events.
+			fmt.Fprintln(w, `{"type": "ADDED", "object": {"login": "jdoe", "loginCount": 1}, "resourceVersion": "1"}`)
+			fmt.Fprintln(w, `{"type": "MODIFIED", "object": {"login": "jdoe", "loginCount": 2}, "resourceVersion": "2"}`)
+			return
+		}
+		fmt.Fprintln(w, `{"type": "DELETED", "object": {"login": "jdoe", "loginCount": 2}, "resourceVersion": "3"}`)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := client.UserIdMapper.Watch(ctx, fastWatch)
+	if err != nil {
+		t.Fatalf("UserIdMappers.Watch returned error: %v", err)
+	}
+
+	var got []string
+	for i := 0; i < 3; i++ {
+		e := nextEvent(t, events)
+		got = append(got, fmt.Sprintf("%s %s %s %d", e.ResourceVersion, e.Type, e.Object.Credential, e.Object.LoginCount))
+	}
+	want := []string{"1 ADDED jdoe 1", "2 MODIFIED jdoe 2", "3 DELETED jdoe 2"}
+	if !cmp.Equal(got, want) {
+		t.Errorf("UserIdMappers.Watch sent %q, want %q", got, want)
+	}
+	if want := []string{"", "2"}; !cmp.Equal(versions, want) {
+		t.Errorf("UserIdMappers.Watch requested versions %q, want %q", versions, want)
+	}
+
+	cancel()
+	checkClosed(t, events)
+}
+
+func TestResourceClient_Watch_cutMidEvent(t *testing.T) {
+	// Responses whose connection is closed in the middle of the second
+	// event, ending the body cleanly or before its announced length.
+	tests := map[string]string{
+		"close":          "HTTP/1.1 200 OK\r\nConnection: close\r\n\r\n",
+		"content length": "HTTP/1.1 200 OK\r\nContent-Length: 1000\r\n\r\n",
+	}
+	for name, header := range tests {
+		t.Run(name, func(t *testing.T) {
+			client, mux, _, teardown := setup()
+			defer teardown()
+
+			var versions []string
+			mux.HandleFunc("/"+mapperResource+"LIST/", func(w http.ResponseWriter, r *http.Request) {
+				versions = append(versions, r.FormValue("resourceVersion"))
+				if len(versions) == 1 {
+					conn, _, err := w.(http.Hijacker).Hijack()
+					if err != nil {
+						t.Fatalf("Hijack returned error: %v", err)
+					}
+					fmt.Fprint(conn, header)
+					fmt.Fprintln(conn, `{"type": "ADDED", "object": {"login": "jdoe"}, "resourceVersion": "1"}`)
+					fmt.Fprint(conn, `{"type": "MODIFIED", "object": {"lo`)
+					conn.Close()
+					return
+				}
+				fmt.Fprintln(w, `{"type": "MODIFIED", "object": {"login": "jdoe", "loginCount": 1}, "resourceVersion": "2"}`)
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			events, err := client.UserIdMapper.Watch(ctx, fastWatch)
+			if err != nil {
+				t.Fatalf("UserIdMappers.Watch returned error: %v", err)
+			}
+
+			var got []string
+			for i := 0; i < 2; i++ {
+				e := nextEvent(t, events)
+				got = append(got, fmt.Sprintf("%s %s %v", e.ResourceVersion, e.Type, e.Err))
+			}
+			want := []string{"1 ADDED <nil>", "2 MODIFIED <nil>"}
+			if !cmp.Equal(got, want) {
+				t.Errorf("UserIdMappers.Watch sent %q, want %q", got, want)
+			}
+			if want := []string{"", "1"}; !cmp.Equal(versions, want) {
+				t.Errorf("UserIdMappers.Watch requested versions %q, want %q", versions, want)
+			}
+		})
+	}
+}
+
+func TestTokensService_Watch_serverSentEvents(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	mux.HandleFunc("/"+tokenResource+"LIST/", func(w http.ResponseWriter, r *http.Request) {
+		if v := r.FormValue("resourceVersion"); v != "7" {
+			t.Errorf("resourceVersion = %q, want 7", v)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "event: ADDED\nid: 8\ndata: {\"metadata\":\ndata: {\"uid\": \"1\"}}\n\n")
+		fmt.Fprint(w, "data: {\"type\": \"BOOKMARK\", \"object\": {}}\n\n")
+		fmt.Fprint(w, "id: 10\ndata: {\"type\": \"DELETED\", \"object\": {\"metadata\": {\"uid\": \"1\"}}}\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := client.Token.Watch(ctx, &WatchOptions{ResourceVersion: "7"})
+	if err != nil {
+		t.Fatalf("Tokens.Watch returned error: %v", err)
+	}
+
+	if e := nextEvent(t, events); e.Type != EventAdded || e.ResourceVersion != "8" || e.Object.Metadata.UID != "1" {
+		t.Errorf("Tokens.Watch sent %+v, want token 1 added at version 8", e)
+	}
+	if e := nextEvent(t, events); e.Type != EventError || e.Err == nil {
+		t.Errorf("Tokens.Watch sent %+v, want an error for the unknown type", e)
+	}
+	if e := nextEvent(t, events); e.Type != EventDeleted || e.ResourceVersion != "10" {
+		t.Errorf("Tokens.Watch sent %+v, want token 1 deleted at version 10", e)
+	}
+}
+
+func TestResourceClient_Watch_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    error
+	}{
+		{"not found", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Not Found", http.StatusNotFound)
+		}, ErrNotFound},
+		{"gone", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Gone", http.StatusGone)
+		}, ErrWatchExpired},
+		{"expired event", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintln(w, `{"type": "ERROR", "object": {"code": 410, "message": "too old resource version"}}`)
+		}, ErrWatchExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, mux, _, teardown := setup()
+			defer teardown()
+			mux.HandleFunc("/"+mapperResource+"LIST/", tt.handler)
+
+			events, err := client.UserIdMapper.Watch(context.Background(), fastWatch)
+			if err != nil {
+				t.Fatalf("UserIdMappers.Watch returned error: %v", err)
+			}
+			if e := nextEvent(t, events); e.Type != EventError || !errors.Is(e.Err, tt.want) {
+				t.Errorf("UserIdMappers.Watch sent %+v, want error %v", e, tt.want)
+			}
+			checkClosed(t, events)
+		})
+	}
+}
+
+func TestResourceClient_Watch_serverErrorRetried(t *testing.T) {
+	client, mux, _, teardown := setup()
+	defer teardown()
+
+	calls := 0
+	mux.HandleFunc("/"+mapperResource+"LIST/", func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+			return
+		}
+		fmt.Fprintln(w, `{"type": "ADDED", "object": {"login": "jdoe"}, "resourceVersion": "1"}`)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := client.UserIdMapper.Watch(ctx, fastWatch)
+	if err != nil {
+		t.Fatalf("UserIdMappers.Watch returned error: %v", err)
+	}
+	if e := nextEvent(t, events); e.Type != EventAdded || calls != 3 {
+		t.Errorf("UserIdMappers.Watch sent %+v after %d calls, want an added mapper after 3", e, calls)
+	}
+}
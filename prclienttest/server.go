@@ -0,0 +1,823 @@
+/*
+Package prclienttest provides an in-memory fake of the PavedRoad API for
+testing code built on prclient without network access.
+
+The fake serves the prTokens and prUserIdMappers resources of every
+namespace:
+
+Verbs to Functions
+------   ---------
+POST     create, assigning a UID to tokens without one
+GET      get, or list with paging on the LIST resources
+PUT      replace
+PATCH    edit with a whole object, or apply a JSON merge patch or JSON patch
+DELETE   delete
+
+Mappers are versioned: every write bumps their ObjVersion and an If-Match
+header must match it. Requests are recorded and errors, including 202
+Accepted, can be injected with Server.Inject.
+
+	srv := prclienttest.NewServer()
+	defer srv.Close()
+	srv.AddToken(prclienttest.DefaultNamespace, prclient.Token{...})
+	client := srv.Client()
+*/
+package prclienttest
+
+import (
+	"bytes"
+	"clients/prclient"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultNamespace is the namespace targeted by the client returned by
+	// Server.Client.
+	DefaultNamespace = "pavedroad.io"
+
+	// DefaultPerPage is the page size of lists without a per_page parameter.
+	DefaultPerPage = 30
+
+	pathPrefix = "/api/v1/namespace/"
+
+	tokenResource  = "prTokens"
+	mapperResource = "prUserIdMappers"
+	listSuffix     = "LIST"
+)
+
+// Request is a request received by the Server.
+type Request struct {
+	Method    string
+	Namespace string // namespace targeted, e.g. DefaultNamespace
+	Path      string // path below the namespace, e.g. prTokens/1
+	Query     url.Values
+	Header    http.Header
+	Body      []byte
+}
+
+// Fault makes the Server answer the matching requests with Status instead
+// of serving them.
+type Fault struct {
+	Method string // empty matches every method
+	Path   string // path below the namespace, e.g. prTokens/1; empty matches every path
+	Status int    // e.g. http.StatusAccepted or http.StatusServiceUnavailable
+	Body   string // defaults to a JSON error message for error statuses
+	Header http.Header
+	Times  int // number of requests answered; zero means all of them
+}
+
+func (f *Fault) matches(method, path string) bool {
+	return (f.Method == "" || f.Method == method) &&
+		(f.Path == "" || strings.Trim(f.Path, "/") == strings.Trim(path, "/"))
+}
+
+// Server is an in-memory fake of the PavedRoad API. Its zero value is not
+// usable; create it with NewServer.
+type Server struct {
+	// URL of the server, e.g. http://127.0.0.1:1234.
+	URL string
+
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	tokens   *collection[prclient.Token]
+	mappers  *collection[prclient.UserIdMapper]
+	requests []Request
+	faults   []*Fault
+	nextUID  int
+}
+
+// NewServer starts and returns a new Server with no data. The caller
+// should call Close when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{}
+	s.tokens = &collection[prclient.Token]{
+		resource: tokenResource,
+		keyName:  "uid",
+		key:      func(t *prclient.Token) string { return t.Metadata.UID },
+		setKey:   func(t *prclient.Token, k string) { t.Metadata.UID = k },
+		newKey:   s.newUID,
+		touch: func(t, old *prclient.Token, now *prclient.Timestamp) {
+			t.Created = now
+			if old != nil {
+				t.Created = old.Created
+			}
+			t.Updated = now
+		},
+		filter: filterToken,
+	}
+	s.mappers = &collection[prclient.UserIdMapper]{
+		resource: mapperResource,
+		keyName:  "login",
+		key:      func(m *prclient.UserIdMapper) string { return m.Credential },
+		setKey:   func(m *prclient.UserIdMapper, k string) { m.Credential = k },
+		touch: func(m, old *prclient.UserIdMapper, now *prclient.Timestamp) {
+			m.Created, m.ObjVersion = now, "1"
+			if old != nil {
+				v, _ := strconv.Atoi(old.ObjVersion)
+				m.Created, m.ObjVersion = old.Created, strconv.Itoa(v+1)
+			}
+			m.Updated = now
+		},
+		version: func(m *prclient.UserIdMapper) string { return m.ObjVersion },
+	}
+	s.srv = httptest.NewServer(s)
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// Client returns a prclient.Client targeting DefaultNamespace of s. Use
+// its Namespace method to target other namespaces.
+func (s *Server) Client() *prclient.Client {
+	c := prclient.NewClient(s.srv.Client())
+	u, _ := url.Parse(s.URL + pathPrefix + DefaultNamespace + "/")
+	c.BaseURL, c.UploadURL = u, u
+	return c
+}
+
+// newUID returns a new unique, increasing UID. It is called with s.mu held.
+func (s *Server) newUID() string {
+	s.nextUID++
+	return fmt.Sprintf("00000000-0000-4000-8000-%012d", s.nextUID)
+}
+
+// AddToken stores a copy of t in namespace ns, assigning it a UID if it
+// has none, and returns the stored token. A token with the same UID is
+// replaced, as if updated by another client.
+func (s *Server) AddToken(ns string, t prclient.Token) *prclient.Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens.add(ns, &t)
+}
+
+// Token returns a copy of the token uid of namespace ns.
+func (s *Server) Token(ns, uid string) (*prclient.Token, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens.get(ns, uid)
+}
+
+// Tokens returns copies of the tokens of namespace ns, ordered by UID.
+func (s *Server) Tokens(ns string) []*prclient.Token {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tokens.list(ns)
+}
+
+// AddUserIdMapper stores a copy of m in namespace ns and returns the
+// stored mapper. A mapper for the same credential is replaced, as if
+// updated by another client, so its version is bumped.
+func (s *Server) AddUserIdMapper(ns string, m prclient.UserIdMapper) *prclient.UserIdMapper {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mappers.add(ns, &m)
+}
+
+// UserIdMapper returns a copy of the mapper for cred of namespace ns.
+func (s *Server) UserIdMapper(ns, cred string) (*prclient.UserIdMapper, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mappers.get(ns, cred)
+}
+
+// UserIdMappers returns copies of the mappers of namespace ns, ordered by
+// credential.
+func (s *Server) UserIdMappers(ns string) []*prclient.UserIdMapper {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mappers.list(ns)
+}
+
+// Inject adds a fault. Faults are checked in the order they were added and
+// the first matching one answers the request.
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// Requests returns the requests received so far, oldest first.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// ResetRequests forgets the requests received so far.
+func (s *Server) ResetRequests() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !strings.HasPrefix(r.URL.Path, pathPrefix) {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, pathPrefix), "/", 2)
+	ns, path := parts[0], ""
+	if len(parts) == 2 {
+		path = parts[1]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, Request{
+		Method:    r.Method,
+		Namespace: ns,
+		Path:      path,
+		Query:     r.URL.Query(),
+		Header:    r.Header.Clone(),
+		Body:      body,
+	})
+
+	if s.fault(w, r.Method, path) {
+		return
+	}
+
+	resource, key := path, ""
+	if i := strings.Index(path, "/"); i >= 0 {
+		resource, key = path[:i], path[i+1:]
+	}
+	req := &request{Request: r, ns: ns, key: key, body: body}
+	switch resource {
+	case tokenResource:
+		serve(w, req, s.tokens)
+	case tokenResource + listSuffix:
+		serveList(w, req, s.tokens)
+	case mapperResource:
+		serve(w, req, s.mappers)
+	case mapperResource + listSuffix:
+		serveList(w, req, s.mappers)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// fault answers the request with the first matching fault, if any.
+func (s *Server) fault(w http.ResponseWriter, method, path string) bool {
+	for i, f := range s.faults {
+		if !f.matches(method, path) {
+			continue
+		}
+		if f.Times > 0 {
+			if f.Times--; f.Times == 0 {
+				s.faults = append(s.faults[:i:i], s.faults[i+1:]...)
+			}
+		}
+
+		for k, v := range f.Header {
+			w.Header()[k] = v
+		}
+		if f.Body == "" && f.Status >= 400 {
+			writeError(w, f.Status, http.StatusText(f.Status))
+			return true
+		}
+		w.WriteHeader(f.Status)
+		fmt.Fprint(w, f.Body)
+		return true
+	}
+	return false
+}
+
+// request is a request for a resource of a namespace.
+type request struct {
+	*http.Request
+	ns   string
+	key  string
+	body []byte
+}
+
+// collection holds the objects of a resource in every namespace.
+type collection[T any] struct {
+	resource string
+	keyName  string                                     // JSON name of the key field
+	key      func(*T) string                            // returns the key of an object
+	setKey   func(*T, string)                           // sets the key of an object
+	newKey   func() string                              // assigns keys to new objects; nil if clients must
+	touch    func(obj, old *T, now *prclient.Timestamp) // stamps a written object; old is nil on create
+	version  func(*T) string                            // returns the version checked by If-Match; nil if unversioned
+	filter   func(*T, url.Values) bool                  // reports whether a listed object matches the query; may be nil
+
+	objects map[string]map[string]*T
+}
+
+func (c *collection[T]) add(ns string, obj *T) *T {
+	if c.key(obj) == "" && c.newKey != nil {
+		c.setKey(obj, c.newKey())
+	}
+	c.put(ns, obj, c.objects[ns][c.key(obj)])
+	return clone(obj)
+}
+
+func (c *collection[T]) get(ns, key string) (*T, bool) {
+	obj, ok := c.objects[ns][key]
+	if !ok {
+		return nil, false
+	}
+	return clone(obj), true
+}
+
+func (c *collection[T]) list(ns string) []*T {
+	keys := make([]string, 0, len(c.objects[ns]))
+	for k := range c.objects[ns] {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	objs := make([]*T, len(keys))
+	for i, k := range keys {
+		objs[i] = clone(c.objects[ns][k])
+	}
+	return objs
+}
+
+// put stores obj, replacing old if any.
+func (c *collection[T]) put(ns string, obj, old *T) {
+	c.touch(obj, old, &prclient.Timestamp{Time: time.Now().UTC()})
+	if c.objects == nil {
+		c.objects = make(map[string]map[string]*T)
+	}
+	if c.objects[ns] == nil {
+		c.objects[ns] = make(map[string]*T)
+	}
+	c.objects[ns][c.key(obj)] = clone(obj)
+}
+
+// serve handles the requests for a single object of c.
+func serve[T any](w http.ResponseWriter, r *request, c *collection[T]) {
+	if r.Method == "POST" {
+		if r.key != "" {
+			writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		create(w, r, c)
+		return
+	}
+
+	old, ok := c.objects[r.ns][r.key]
+	if r.key == "" || !ok {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("%s %q not found", c.resource, r.key),
+			prclient.Error{Resource: c.resource, Field: c.keyName, Code: "missing"})
+		return
+	}
+
+	switch r.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, old)
+	case "DELETE":
+		if !checkVersion(w, r, c, old) {
+			return
+		}
+		delete(c.objects[r.ns], r.key)
+		w.WriteHeader(http.StatusNoContent)
+	case "PUT", "PATCH":
+		if !checkVersion(w, r, c, old) {
+			return
+		}
+		obj, status, err := decodeUpdate(r, old)
+		if err != nil {
+			writeError(w, status, err.Error())
+			return
+		}
+		c.setKey(obj, r.key)
+		c.put(r.ns, obj, old)
+		writeJSON(w, http.StatusOK, obj)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func create[T any](w http.ResponseWriter, r *request, c *collection[T]) {
+	obj := new(T)
+	if err := json.Unmarshal(r.body, obj); err != nil {
+		writeError(w, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	key := c.key(obj)
+	switch {
+	case key == "" && c.newKey != nil:
+		c.setKey(obj, c.newKey())
+	case key == "":
+		writeError(w, http.StatusUnprocessableEntity, "Validation Failed",
+			prclient.Error{Resource: c.resource, Field: c.keyName, Code: "missing_field"})
+		return
+	}
+	if _, ok := c.objects[r.ns][c.key(obj)]; ok {
+		writeError(w, http.StatusConflict, fmt.Sprintf("%s %q already exists", c.resource, c.key(obj)),
+			prclient.Error{Resource: c.resource, Field: c.keyName, Code: "already_exists"})
+		return
+	}
+
+	c.put(r.ns, obj, nil)
+	writeJSON(w, http.StatusCreated, obj)
+}
+
+// checkVersion answers 412 Precondition Failed if the If-Match header of r
+// does not match the version of old.
+func checkVersion[T any](w http.ResponseWriter, r *request, c *collection[T], old *T) bool {
+	want := r.Header.Get("If-Match")
+	if want == "" || c.version == nil {
+		return true
+	}
+	if got := c.version(old); strings.Trim(want, `"`) != got {
+		writeError(w, http.StatusPreconditionFailed,
+			fmt.Sprintf("%s %q is at version %s", c.resource, r.key, got))
+		return false
+	}
+	return true
+}
+
+// decodeUpdate returns the object resulting from the PUT or PATCH request
+// r on old, with the status to answer on error.
+func decodeUpdate[T any](r *request, old *T) (*T, int, error) {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch {
+	case r.Method == "PATCH" && mt == "application/merge-patch+json":
+		var patch interface{}
+		if err := json.Unmarshal(r.body, &patch); err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("Problems parsing JSON")
+		}
+		var doc interface{}
+		b, _ := json.Marshal(old)
+		json.Unmarshal(b, &doc)
+		b, _ = json.Marshal(mergePatch(doc, patch))
+
+		obj := new(T)
+		if err := json.Unmarshal(b, obj); err != nil {
+			return nil, http.StatusUnprocessableEntity, err
+		}
+		return obj, 0, nil
+	case r.Method == "PATCH" && mt == "application/json-patch+json":
+		var ops []prclient.PatchOperation
+		if err := json.Unmarshal(r.body, &ops); err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("Problems parsing JSON")
+		}
+		var doc interface{}
+		b, _ := json.Marshal(old)
+		json.Unmarshal(b, &doc)
+		doc, status, err := jsonPatch(doc, ops)
+		if err != nil {
+			return nil, status, err
+		}
+		b, _ = json.Marshal(doc)
+
+		obj := new(T)
+		if err := json.Unmarshal(b, obj); err != nil {
+			return nil, http.StatusUnprocessableEntity, err
+		}
+		return obj, 0, nil
+	case mt == "" || mt == "application/json":
+		obj := new(T)
+		if err := json.Unmarshal(r.body, obj); err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("Problems parsing JSON")
+		}
+		return obj, 0, nil
+	}
+	return nil, http.StatusUnsupportedMediaType, fmt.Errorf("Unsupported media type %q", mt)
+}
+
+// mergePatch applies the RFC 7386 merge patch to doc.
+func mergePatch(doc, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	d, ok := doc.(map[string]interface{})
+	if !ok {
+		d = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(d, k)
+			continue
+		}
+		d[k] = mergePatch(d[k], v)
+	}
+	return d
+}
+
+// jsonPatch applies the RFC 6902 JSON patch ops to doc, with the status
+// to answer on error: 409 Conflict if a test operation fails, 422
+// Unprocessable Entity if an operation is invalid.
+func jsonPatch(doc interface{}, ops []prclient.PatchOperation) (interface{}, int, error) {
+	for _, op := range ops {
+		path, err := jsonPointer(op.Path)
+		if err != nil {
+			return nil, http.StatusUnprocessableEntity, err
+		}
+		var from []string
+		if op.Op == "move" || op.Op == "copy" {
+			if from, err = jsonPointer(op.From); err != nil {
+				return nil, http.StatusUnprocessableEntity, err
+			}
+		}
+
+		switch op.Op {
+		case "add":
+			doc, err = addValue(doc, path, op.Value)
+		case "remove":
+			doc, _, err = removeValue(doc, path)
+		case "replace":
+			if _, err = getValue(doc, path); err == nil {
+				doc, err = replaceValue(doc, path, op.Value)
+			}
+		case "move":
+			var v interface{}
+			if len(from) < len(path) && strings.HasPrefix(op.Path, op.From+"/") {
+				err = fmt.Errorf("Cannot move %s into itself", op.From)
+			} else if doc, v, err = removeValue(doc, from); err == nil {
+				doc, err = addValue(doc, path, v)
+			}
+		case "copy":
+			var v interface{}
+			if v, err = getValue(doc, from); err == nil {
+				// Copy the value so that later operations change only one
+				// of its occurrences.
+				b, _ := json.Marshal(v)
+				json.Unmarshal(b, &v)
+				doc, err = addValue(doc, path, v)
+			}
+		case "test":
+			var v interface{}
+			if v, err = getValue(doc, path); err == nil && !reflect.DeepEqual(v, op.Value) {
+				return nil, http.StatusConflict, fmt.Errorf("Test of %s failed", op.Path)
+			}
+		default:
+			err = fmt.Errorf("Unknown patch operation %q", op.Op)
+		}
+		if err != nil {
+			return nil, http.StatusUnprocessableEntity, err
+		}
+	}
+	return doc, 0, nil
+}
+
+// jsonPointer returns the unescaped reference tokens of the RFC 6901 JSON
+// pointer p.
+func jsonPointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, fmt.Errorf("Invalid JSON pointer %q", p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses the array index token t, which must be below n.
+func arrayIndex(t string, n int) (int, error) {
+	i, err := strconv.Atoi(t)
+	if err != nil || i < 0 || i >= n || (len(t) > 1 && t[0] == '0') {
+		return 0, fmt.Errorf("Invalid array index %q", t)
+	}
+	return i, nil
+}
+
+// getValue returns the value of doc at path.
+func getValue(doc interface{}, path []string) (interface{}, error) {
+	for _, t := range path {
+		switch d := doc.(type) {
+		case map[string]interface{}:
+			v, ok := d[t]
+			if !ok {
+				return nil, fmt.Errorf("Member %q not found", t)
+			}
+			doc = v
+		case []interface{}:
+			i, err := arrayIndex(t, len(d))
+			if err != nil {
+				return nil, err
+			}
+			doc = d[i]
+		default:
+			return nil, fmt.Errorf("Cannot traverse %q", t)
+		}
+	}
+	return doc, nil
+}
+
+// updateParent returns doc in which the object or array containing the
+// value at path is replaced by the result of f.
+func updateParent(doc interface{}, path []string, f func(parent interface{}, t string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return f(doc, path[0])
+	}
+	child, err := getValue(doc, path[:1])
+	if err != nil {
+		return nil, err
+	}
+	if child, err = updateParent(child, path[1:], f); err != nil {
+		return nil, err
+	}
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		d[path[0]] = child
+	case []interface{}:
+		i, _ := arrayIndex(path[0], len(d))
+		d[i] = child
+	}
+	return doc, nil
+}
+
+// addValue returns doc with v added at path.
+func addValue(doc interface{}, path []string, v interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	return updateParent(doc, path, func(parent interface{}, t string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[t] = v
+			return p, nil
+		case []interface{}:
+			if t == "-" {
+				return append(p, v), nil
+			}
+			i, err := arrayIndex(t, len(p)+1)
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, nil)
+			copy(p[i+1:], p[i:])
+			p[i] = v
+			return p, nil
+		}
+		return nil, fmt.Errorf("Cannot add %q", t)
+	})
+}
+
+// replaceValue returns doc with the existing value at path replaced by v.
+func replaceValue(doc interface{}, path []string, v interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return v, nil
+	}
+	return updateParent(doc, path, func(parent interface{}, t string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			p[t] = v
+			return p, nil
+		case []interface{}:
+			i, _ := arrayIndex(t, len(p))
+			p[i] = v
+			return p, nil
+		}
+		return nil, fmt.Errorf("Cannot replace %q", t)
+	})
+}
+
+// removeValue returns doc without the value at path, and that value.
+func removeValue(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("Cannot remove the whole document")
+	}
+	var removed interface{}
+	doc, err := updateParent(doc, path, func(parent interface{}, t string) (interface{}, error) {
+		switch p := parent.(type) {
+		case map[string]interface{}:
+			v, ok := p[t]
+			if !ok {
+				return nil, fmt.Errorf("Member %q not found", t)
+			}
+			removed = v
+			delete(p, t)
+			return p, nil
+		case []interface{}:
+			i, err := arrayIndex(t, len(p))
+			if err != nil {
+				return nil, err
+			}
+			removed = p[i]
+			return append(p[:i], p[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("Cannot remove %q", t)
+	})
+	return doc, removed, err
+}
+
+// serveList handles the list requests of c. Objects are ordered by key
+// and can be paged both with the page and per_page parameters, announced
+// by a Link header, and with the since parameter.
+func serveList[T any](w http.ResponseWriter, r *request, c *collection[T]) {
+	if r.Method != "GET" {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	q := r.URL.Query()
+
+	var objs []*T
+	for _, obj := range c.list(r.ns) {
+		if since := q.Get("since"); since != "" && c.key(obj) <= since {
+			continue
+		}
+		if c.filter != nil && !c.filter(obj, q) {
+			continue
+		}
+		objs = append(objs, obj)
+	}
+
+	page, perPage := intParam(q, "page", 1), intParam(q, "per_page", DefaultPerPage)
+	last := (len(objs) + perPage - 1) / perPage
+	if last < 1 {
+		last = 1
+	}
+	start, end := (page-1)*perPage, page*perPage
+	if start > len(objs) {
+		start = len(objs)
+	}
+	if end > len(objs) {
+		end = len(objs)
+	}
+
+	var links []string
+	link := func(p int, rel string) {
+		q.Set("page", strconv.Itoa(p))
+		u := url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path, RawQuery: q.Encode()}
+		links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel))
+	}
+	if page < last {
+		link(page+1, "next")
+		link(last, "last")
+	}
+	if page > 1 {
+		link(1, "first")
+		link(page-1, "prev")
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	writeJSON(w, http.StatusOK, append([]*T{}, objs[start:end]...))
+}
+
+// filterToken applies the expiringBefore parameter of token lists.
+func filterToken(t *prclient.Token, q url.Values) bool {
+	v := q.Get("expiringBefore")
+	if v == "" {
+		return true
+	}
+	before, err := time.Parse(time.RFC3339, v)
+	return err != nil || t.ExpiresBefore(before)
+}
+
+func intParam(q url.Values, name string, def int) int {
+	if n, err := strconv.Atoi(q.Get(name)); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+func clone[T any](obj *T) *T {
+	b, _ := json.Marshal(obj)
+	c := new(T)
+	json.Unmarshal(b, c)
+	return c
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(v)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// writeError answers with a body decoded by prclient into an
+// *prclient.ErrorResponse.
+func writeError(w http.ResponseWriter, status int, message string, errs ...prclient.Error) {
+	writeJSON(w, status, struct {
+		Message string           `json:"message"`
+		Errors  []prclient.Error `json:"errors,omitempty"`
+	}{message, errs})
+}
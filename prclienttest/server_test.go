@@ -0,0 +1,193 @@
+package prclienttest
+
+import (
+	"clients/prclient"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestServer_tokens(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+	ctx := context.Background()
+
+	created, _, err := client.Token.Create(ctx, prclient.Token{Metadata: prclient.Metadata{Site: "github", Token: "s3cr3t"}, Active: true})
+	if err != nil {
+		t.Fatalf("Tokens.Create returned error: %v", err)
+	}
+	uid := created.Metadata.UID
+	if uid == "" || created.Created == nil {
+		t.Errorf("Tokens.Create returned %+v, want a UID and a creation time", created)
+	}
+
+	got, _, err := client.Token.Get(ctx, uid)
+	if err != nil || got.Metadata.Site != "github" {
+		t.Errorf("Tokens.Get returned %+v, %v", got, err)
+	}
+
+	got.Active = false
+	if _, _, err := client.Token.Replace(ctx, got, uid); err != nil {
+		t.Errorf("Tokens.Replace returned error: %v", err)
+	}
+	if _, _, err := client.Token.Patch(ctx, uid, prclient.MergePatch{"metadata": map[string]interface{}{"site": "gitlab"}}); err != nil {
+		t.Errorf("Tokens.Patch returned error: %v", err)
+	}
+	stored, _ := srv.Token(DefaultNamespace, uid)
+	if stored.Active || stored.Metadata.Site != "gitlab" || stored.Metadata.Token != "s3cr3t" {
+		t.Errorf("stored token is %+v", stored)
+	}
+
+	if _, err := client.Token.Delete(ctx, uid); err != nil {
+		t.Errorf("Tokens.Delete returned error: %v", err)
+	}
+	if _, _, err := client.Token.Get(ctx, uid); !errors.Is(err, prclient.ErrNotFound) {
+		t.Errorf("Tokens.Get returned error %v, want ErrNotFound", err)
+	}
+}
+
+func TestServer_jsonPatch(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+	ctx := context.Background()
+	uid := srv.AddToken(DefaultNamespace, prclient.Token{Metadata: prclient.Metadata{Site: "github", Scope: []string{"repo", "user"}}}).Metadata.UID
+
+	patch := prclient.JSONPatch{
+		{Op: "test", Path: "/metadata/site", Value: "github"},
+		{Op: "replace", Path: "/metadata/site", Value: "gitlab"},
+		{Op: "add", Path: "/metadata/scope/1", Value: "admin"},
+		{Op: "remove", Path: "/metadata/scope/0"},
+		{Op: "add", Path: "/metadata/scope/-", Value: "read"},
+		{Op: "copy", From: "/metadata/site", Path: "/metadata/name"},
+		{Op: "move", From: "/metadata/scope/2", Path: "/metadata/endPoint"},
+		{Op: "add", Path: "/active", Value: true},
+	}
+	if _, _, err := client.Token.Patch(ctx, uid, patch); err != nil {
+		t.Fatalf("Tokens.Patch returned error: %v", err)
+	}
+	stored, _ := srv.Token(DefaultNamespace, uid)
+	md := stored.Metadata
+	if md.Site != "gitlab" || md.Name != "gitlab" || md.EndPoint != "read" || strings.Join(md.Scope, ",") != "admin,user" || !stored.Active {
+		t.Errorf("stored token is %+v", stored)
+	}
+
+	failed := prclient.JSONPatch{
+		{Op: "replace", Path: "/metadata/site", Value: "bitbucket"},
+		{Op: "test", Path: "/metadata/site", Value: "github"},
+	}
+	if _, _, err := client.Token.Patch(ctx, uid, failed); !errors.Is(err, prclient.ErrConflict) {
+		t.Errorf("Tokens.Patch returned error %v, want ErrConflict", err)
+	}
+	invalid := prclient.JSONPatch{{Op: "remove", Path: "/metadata/scope/5"}}
+	if _, _, err := client.Token.Patch(ctx, uid, invalid); !errors.Is(err, prclient.ErrValidation) {
+		t.Errorf("Tokens.Patch returned error %v, want ErrValidation", err)
+	}
+	if stored, _ := srv.Token(DefaultNamespace, uid); stored.Metadata.Site != "gitlab" {
+		t.Errorf("failed patches changed the token to %+v", stored)
+	}
+}
+
+func TestServer_listPaging(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	for i := 0; i < 5; i++ {
+		srv.AddToken(DefaultNamespace, prclient.Token{})
+	}
+	srv.AddToken("other", prclient.Token{})
+
+	opt := &prclient.TokenListOptions{ListOptions: prclient.ListOptions{PerPage: 2}}
+	tokens, err := srv.Client().Token.ListAll(context.Background(), opt)
+	if err != nil {
+		t.Fatalf("Tokens.ListAll returned error: %v", err)
+	}
+	if len(tokens) != 5 {
+		t.Errorf("Tokens.ListAll returned %d tokens, want 5", len(tokens))
+	}
+
+	var pages []string
+	for _, r := range srv.Requests() {
+		pages = append(pages, r.Query.Get("page"))
+	}
+	if want := []string{"", "2", "3"}; len(pages) != 3 || pages[1] != want[1] || pages[2] != want[2] {
+		t.Errorf("pages requested %q, want %q", pages, want)
+	}
+
+	tokens, _, err = srv.Client().Namespace("other").Token.List(context.Background(), nil)
+	if err != nil || len(tokens) != 1 {
+		t.Errorf("Tokens.List in namespace other returned %d tokens, %v, want 1", len(tokens), err)
+	}
+}
+
+func TestServer_mapperVersions(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+	ctx := context.Background()
+
+	m, _, err := client.UserIdMapper.Create(ctx, prclient.UserIdMapper{Credential: "jdoe"})
+	if err != nil {
+		t.Fatalf("UserIdMappers.Create returned error: %v", err)
+	}
+	if m.ObjVersion != "1" {
+		t.Errorf("UserIdMappers.Create returned version %q, want 1", m.ObjVersion)
+	}
+
+	// A concurrent writer bumps the version.
+	srv.AddUserIdMapper(DefaultNamespace, prclient.UserIdMapper{Credential: "jdoe", ObjVersion: "1", LoginCount: 10})
+
+	m.LoginCount++
+	var verr *prclient.VersionConflictError
+	if _, _, err := client.UserIdMapper.Replace(ctx, m, "jdoe"); !errors.As(err, &verr) {
+		t.Errorf("UserIdMappers.Replace returned error %v, want *VersionConflictError", err)
+	}
+
+	updated, _, err := client.UserIdMapper.UpdateWithRetry(ctx, "jdoe", func(m *prclient.UserIdMapper) error {
+		m.LoginCount++
+		return nil
+	})
+	if err != nil || updated.LoginCount != 11 {
+		t.Errorf("UserIdMappers.UpdateWithRetry returned %+v, %v, want 11 logins", updated, err)
+	}
+
+	if _, _, err := client.UserIdMapper.Create(ctx, prclient.UserIdMapper{Credential: "jdoe"}); !errors.Is(err, prclient.ErrConflict) {
+		t.Errorf("UserIdMappers.Create returned error %v, want ErrConflict", err)
+	}
+	if _, _, err := client.UserIdMapper.Create(ctx, prclient.UserIdMapper{}); !errors.Is(err, prclient.ErrValidation) {
+		t.Errorf("UserIdMappers.Create returned error %v, want ErrValidation", err)
+	}
+}
+
+func TestServer_Inject(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+	client := srv.Client()
+	ctx := context.Background()
+	tok := srv.AddToken(DefaultNamespace, prclient.Token{})
+
+	srv.Inject(Fault{Method: "GET", Path: "prTokens/" + tok.Metadata.UID, Status: http.StatusAccepted, Times: 1})
+	srv.Inject(Fault{Path: "prTokensLIST", Status: http.StatusServiceUnavailable})
+
+	var aerr *prclient.AcceptedError
+	if _, _, err := client.Token.Get(ctx, tok.Metadata.UID); !errors.As(err, &aerr) {
+		t.Errorf("Tokens.Get returned error %v, want *AcceptedError", err)
+	}
+	if _, _, err := client.Token.Get(ctx, tok.Metadata.UID); err != nil {
+		t.Errorf("Tokens.Get returned error %v after the fault was used up", err)
+	}
+	if _, _, err := client.Token.List(ctx, nil); !errors.Is(err, prclient.ErrServer) {
+		t.Errorf("Tokens.List returned error %v, want ErrServer", err)
+	}
+
+	reqs := srv.Requests()
+	if len(reqs) != 3 || reqs[2].Method != "GET" || reqs[2].Path != "prTokensLIST/" {
+		t.Errorf("Requests returned %+v", reqs)
+	}
+	srv.ResetRequests()
+	if reqs := srv.Requests(); len(reqs) != 0 {
+		t.Errorf("Requests returned %d requests after ResetRequests, want 0", len(reqs))
+	}
+}